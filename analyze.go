@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/schumann-it/dehydrated-api-go/plugin/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes of the commands.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// domainAnalysis is the metadata GetMetadata returns for a single domain.
+type domainAnalysis struct {
+	Domain   string
+	Metadata map[string]any
+}
+
+// runAnalyze implements the analyze command, which runs the GetMetadata analysis of one or all domains of a
+// certificate directory without the dehydrated-api host and prints the result.
+func runAnalyze(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: analyze [options] <certDir> [domain]")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "table", "Output format: json, yaml or table")
+	accountsDir := flags.String("accounts-dir", "", "dehydrated's accounts directory")
+	domainsTxt := flags.String("domains-txt", "", "dehydrated's domains.txt, required for the coverage of the names")
+	configFile := flags.String("config", "", "JSON file with the plugin configuration")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	write, ok := outputFormats[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "invalid format %q\n", *format)
+		return exitUsage
+	}
+
+	analyses, err := analyzeDomains(flags.Arg(0), flags.Arg(1), *accountsDir, *domainsTxt, *configFile, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if err = write(stdout, analyses); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// analyzeDomains runs GetMetadata for the domain, or for every domain directory in certDir if domain is empty.
+// With domainsTxt the domain entries are read from dehydrated's domains.txt. Without it the names of a certificate
+// are unknown, so the coverage is left out.
+func analyzeDomains(certDir, domain, accountsDir, domainsTxt, configFile string,
+	stderr io.Writer) ([]*domainAnalysis, error) {
+	plugin, err := newCLIPlugin(configFile, stderr)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	defer func() { _, _ = plugin.Close(ctx, &proto.CloseRequest{}) }()
+
+	entries, err := selectEntries(certDir, domain, domainsTxt)
+	if err != nil {
+		return nil, err
+	}
+
+	analyses := make([]*domainAnalysis, 0, len(entries))
+	for _, entry := range entries {
+		dir := entryDir(entry)
+		resp, getErr := plugin.GetMetadata(ctx, &proto.GetMetadataRequest{
+			DomainEntry:      entry,
+			DehydratedConfig: &proto.DehydratedConfig{CertDir: certDir, AccountsDir: accountsDir},
+		})
+		if getErr != nil {
+			return nil, fmt.Errorf("failed to analyze %s: %w", dir, getErr)
+		}
+
+		metadata := make(map[string]any, len(resp.GetMetadata()))
+		for key, value := range resp.GetMetadata() {
+			metadata[key] = value.AsInterface()
+		}
+		if domainsTxt == "" {
+			delete(metadata, "coverage")
+		}
+		analyses = append(analyses, &domainAnalysis{Domain: dir, Metadata: metadata})
+	}
+
+	return analyses, nil
+}
+
+// newCLIPlugin creates and initializes a plugin with the configuration file of a command. The plugin logs warnings
+// to stderr, so they do not mix with the output of the command.
+func newCLIPlugin(configFile string, stderr io.Writer) (*OpensslPlugin, error) {
+	config, err := loadCLIConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	plugin := &OpensslPlugin{
+		logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "openssl-plugin",
+			Level:  hclog.Warn,
+			Output: stderr,
+		}),
+	}
+	if _, err = plugin.Initialize(context.Background(), &proto.InitializeRequest{Config: config}); err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+// loadCLIConfig reads the plugin configuration from a JSON object with the same keys as the plugin config.
+func loadCLIConfig(file string) (map[string]*structpb.Value, error) {
+	config := map[string]*structpb.Value{}
+	if file == "" {
+		return config, nil
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var values map[string]any
+	if err = json.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", file, err)
+	}
+	for key, value := range values {
+		if config[key], err = structpb.NewValue(value); err != nil {
+			return nil, fmt.Errorf("invalid config value %s: %w", key, err)
+		}
+	}
+	return config, nil
+}
+
+// selectDomains returns the domain, or every domain directory of certDir if domain is empty.
+func selectDomains(certDir, domain string) ([]string, error) {
+	if domain != "" {
+		return []string{domain}, nil
+	}
+	return domainDirs(certDir)
+}
+
+// selectEntries returns the domain entries to analyze: those of domainsTxt if given, otherwise one per domain
+// directory with the directory name as domain. A domain selects the entry with that directory or primary domain.
+func selectEntries(certDir, domain, domainsTxt string) ([]*proto.DomainEntry, error) {
+	if domainsTxt == "" {
+		domains, err := selectDomains(certDir, domain)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]*proto.DomainEntry, 0, len(domains))
+		for _, d := range domains {
+			entries = append(entries, &proto.DomainEntry{Domain: d})
+		}
+		return entries, nil
+	}
+
+	entries, err := readDomainsTxt(domainsTxt)
+	if err != nil {
+		return nil, err
+	}
+	if domain == "" {
+		return entries, nil
+	}
+	for _, entry := range entries {
+		if entryDir(entry) == domain || entry.GetDomain() == domain {
+			return []*proto.DomainEntry{entry}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not listed in %s", domain, domainsTxt)
+}
+
+// readDomainsTxt parses dehydrated's domains.txt: one certificate per line with the primary domain followed by the
+// alternative names and optionally "> alias" naming the certificate directory. Lines ending with a backslash are
+// continued on the next line, empty lines and lines starting with # are ignored.
+func readDomainsTxt(file string) ([]*proto.DomainEntry, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domains.txt: %w", err)
+	}
+
+	var entries []*proto.DomainEntry
+	var continued string
+	for _, line := range strings.Split(strings.ReplaceAll(string(b), "\r", ""), "\n") {
+		line = strings.TrimSpace(continued + " " + strings.ToLower(line))
+		continued = ""
+		if strings.HasSuffix(line, "\\") {
+			continued = strings.TrimSuffix(line, "\\")
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		names, alias, _ := strings.Cut(line, ">")
+		fields := strings.Fields(names)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid line in %s: %q", file, line)
+		}
+		entries = append(entries, &proto.DomainEntry{
+			Domain:           fields[0],
+			AlternativeNames: fields[1:],
+			Alias:            strings.TrimSpace(alias),
+		})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no domains in %s", file)
+	}
+	return entries, nil
+}
+
+// entryDir returns the name of the certificate directory of a domain entry, the alias if there is one.
+func entryDir(entry *proto.DomainEntry) string {
+	if entry.GetAlias() != "" {
+		return entry.GetAlias()
+	}
+	return entry.GetDomain()
+}
+
+// domainDirs lists the domain directories of certDir in lexical order.
+func domainDirs(certDir string) ([]string, error) {
+	entries, err := os.ReadDir(certDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", certDir, err)
+	}
+
+	var domains []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			domains = append(domains, entry.Name())
+		}
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("no domain directories in %s", certDir)
+	}
+	return domains, nil
+}
+
+// outputFormats are the writers of the analyze output by format name.
+var outputFormats = map[string]func(io.Writer, []*domainAnalysis) error{
+	"json":  writeJSON,
+	"yaml":  writeYAML,
+	"table": writeTable,
+}
+
+// byDomain returns the metadata keyed by domain, the structure of the json and yaml output.
+func byDomain(analyses []*domainAnalysis) map[string]map[string]any {
+	m := make(map[string]map[string]any, len(analyses))
+	for _, a := range analyses {
+		m[a.Domain] = a.Metadata
+	}
+	return m
+}
+
+// writeJSON writes the metadata keyed by domain as indented JSON.
+func writeJSON(w io.Writer, analyses []*domainAnalysis) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(byDomain(analyses))
+}
+
+// writeYAML writes the metadata keyed by domain as YAML.
+func writeYAML(w io.Writer, analyses []*domainAnalysis) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(byDomain(analyses)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// writeTable writes a summary line per domain followed by the errors reported in the metadata.
+func writeTable(w io.Writer, analyses []*domainAnalysis) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tKEY\tNOT AFTER\tSTATUS\tDAYS\tKEY MATCH\tVERIFIED\tERRORS")
+
+	var details []string
+	for _, a := range analyses {
+		errs := collectErrors(a.Metadata, "")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			a.Domain,
+			describeKey(a.Metadata),
+			notAfter(a.Metadata),
+			text(lookup(a.Metadata, "cert", "validity", "status")),
+			text(lookup(a.Metadata, "cert", "validity", "days_remaining")),
+			text(lookup(a.Metadata, "consistency", "key_matches_certificate")),
+			text(lookup(a.Metadata, "verification", "valid")),
+			len(errs))
+		for _, e := range errs {
+			details = append(details, a.Domain+": "+e)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(details) > 0 {
+		fmt.Fprintln(w)
+		for _, d := range details {
+			fmt.Fprintln(w, d)
+		}
+	}
+	return nil
+}
+
+// describeKey summarizes the private key as type and size or curve, e.g. rsa 2048 or ecdsa P-256.
+func describeKey(metadata map[string]any) string {
+	keyType := text(lookup(metadata, "key", "type"))
+	if curve := lookup(metadata, "key", "curve"); curve != nil {
+		return keyType + " " + text(curve)
+	}
+	if size := lookup(metadata, "key", "size"); size != nil {
+		return keyType + " " + text(size)
+	}
+	return keyType
+}
+
+// notAfter returns the end of the validity period of the certificate, if it could be analyzed.
+func notAfter(metadata map[string]any) string {
+	if lookup(metadata, "cert", "validity") == nil {
+		return "-"
+	}
+	return text(lookup(metadata, "cert", "not_after"))
+}
+
+// lookup returns the value at the path of map keys, or nil if there is none.
+func lookup(v any, path ...string) any {
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// text formats a metadata value for the table, with - for missing values.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// collectErrors returns the error and errors fields anywhere in the metadata, prefixed with their path.
+func collectErrors(v any, path string) []string {
+	var errs []string
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			switch {
+			case key == "error" && v[key] != "":
+				errs = append(errs, errorPrefix(path)+text(v[key]))
+			case key == "errors":
+				for _, e := range asList(v[key]) {
+					errs = append(errs, errorPrefix(path)+errorText(e))
+				}
+			default:
+				errs = append(errs, collectErrors(v[key], strings.TrimPrefix(path+"."+key, "."))...)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			errs = append(errs, collectErrors(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+// errorText formats an entry of an errors list. Structured entries such as verification errors are printed as
+// reason: message.
+func errorText(e any) string {
+	m, ok := e.(map[string]any)
+	if !ok {
+		return text(e)
+	}
+	message, reason := text(m["message"]), text(m["reason"])
+	switch {
+	case reason == "-":
+		return message
+	case message == "-":
+		return reason
+	default:
+		return reason + ": " + message
+	}
+}
+
+// errorPrefix returns the path an error was found at as prefix of the error message.
+func errorPrefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+// asList returns the list value, or nil if the value is not a list.
+func asList(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
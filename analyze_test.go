@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-go/plugin/proto"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// writeTestDomain writes the key, certificate, chain and fullchain of a domain whose certificate expires at notAfter.
+func writeTestDomain(t *testing.T, certDir, domain string, notAfter time.Time) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, key.Public(), caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	files := map[string][]byte{
+		"privkey.pem":   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		"cert.pem":      cert,
+		"chain.pem":     chain,
+		"fullchain.pem": append(append([]byte{}, cert...), chain...),
+	}
+
+	dir := filepath.Join(certDir, domain)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0600))
+	}
+}
+
+func TestRunAnalyze_JSON(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	writeTestDomain(t, certDir, "b.example.com", time.Now().Add(10*24*time.Hour))
+
+	var stdout, stderr bytes.Buffer
+	code := runAnalyze([]string{"-format", "json", certDir}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var result map[string]map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	require.Len(t, result, 2)
+	require.Equal(t, "valid", lookup(result["a.example.com"], "cert", "validity", "status"))
+	require.Equal(t, "expiring", lookup(result["b.example.com"], "cert", "validity", "status"))
+	match, ok := lookup(result["a.example.com"], "consistency", "key_matches_certificate").(bool)
+	require.True(t, ok && match)
+}
+
+func TestRunAnalyze_YAML(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	writeTestDomain(t, certDir, "b.example.com", time.Now().Add(60*24*time.Hour))
+
+	var stdout, stderr bytes.Buffer
+	code := runAnalyze([]string{"-format", "yaml", certDir, "a.example.com"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var result map[string]map[string]any
+	require.NoError(t, yaml.Unmarshal(stdout.Bytes(), &result))
+	require.Len(t, result, 1)
+	require.Equal(t, "ecdsa", lookup(result["a.example.com"], "key", "type"))
+}
+
+func TestRunAnalyze_Table(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	require.NoError(t, os.Remove(filepath.Join(certDir, "a.example.com", "chain.pem")))
+
+	var stdout, stderr bytes.Buffer
+	code := runAnalyze([]string{certDir}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	out := stdout.String()
+	require.Contains(t, out, "DOMAIN")
+	require.Contains(t, out, "a.example.com  ecdsa P-256")
+	require.Contains(t, out, "valid")
+	require.Contains(t, out, "a.example.com: chain: failed to read")
+	// The test CA is not a trusted root
+	require.Contains(t, out, "a.example.com: verification: unknown_authority: x509: certificate signed by unknown authority")
+	require.NotContains(t, out, "map[")
+}
+
+func TestRunAnalyze_Config(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{"expiringDays": 90}`), 0600))
+
+	var stdout, stderr bytes.Buffer
+	code := runAnalyze([]string{"-format", "json", "-config", configFile, certDir}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var result map[string]map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	require.Equal(t, "expiring", lookup(result["a.example.com"], "cert", "validity", "status"))
+}
+
+func TestRunAnalyze_DomainsTxt(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	writeTestDomain(t, certDir, "b.example.com", time.Now().Add(60*24*time.Hour))
+	require.NoError(t, os.Rename(filepath.Join(certDir, "b.example.com"), filepath.Join(certDir, "b-alias")))
+	domainsTxt := filepath.Join(t.TempDir(), "domains.txt")
+	require.NoError(t, os.WriteFile(domainsTxt, []byte("# comment\na.example.com www.a.example.com\n\nB.example.com > b-alias\n"), 0600))
+
+	var stdout, stderr bytes.Buffer
+	code := runAnalyze([]string{"-format", "json", "-domains-txt", domainsTxt, certDir}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var result map[string]map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	require.Len(t, result, 2)
+	require.Equal(t, false, lookup(result["a.example.com"], "coverage", "complete"))
+	require.Equal(t, []any{"www.a.example.com"}, lookup(result["a.example.com"], "coverage", "missing"))
+	require.Equal(t, true, lookup(result["b-alias"], "coverage", "complete"))
+
+	stdout.Reset()
+	code = runAnalyze([]string{"-format", "json", "-domains-txt", domainsTxt, certDir, "b.example.com"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+	var selected map[string]map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &selected))
+	require.Len(t, selected, 1)
+	require.Contains(t, selected, "b-alias")
+}
+
+func TestRunAnalyze_NoCoverageWithoutDomainsTxt(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+
+	var stdout, stderr bytes.Buffer
+	code := runAnalyze([]string{"-format", "json", certDir}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var result map[string]map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	require.NotContains(t, result["a.example.com"], "coverage")
+}
+
+func TestReadDomainsTxt(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []*proto.DomainEntry
+		err      string
+	}{
+		{
+			name:    "entries",
+			content: "example.com www.example.com\n  # comment\n\nExample.org>org\n",
+			expected: []*proto.DomainEntry{
+				{Domain: "example.com", AlternativeNames: []string{"www.example.com"}},
+				{Domain: "example.org", AlternativeNames: []string{}, Alias: "org"},
+			},
+		},
+		{
+			name:    "continued line",
+			content: "example.com \\\n  www.example.com > alias\r\n",
+			expected: []*proto.DomainEntry{
+				{Domain: "example.com", AlternativeNames: []string{"www.example.com"}, Alias: "alias"},
+			},
+		},
+		{name: "no domains", content: "# comment\n", err: "no domains"},
+		{name: "alias only", content: "> alias\n", err: "invalid line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "domains.txt")
+			require.NoError(t, os.WriteFile(file, []byte(tt.content), 0600))
+
+			entries, err := readDomainsTxt(file)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, entries)
+		})
+	}
+}
+
+func TestRunAnalyze_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "missing cert dir", args: []string{}, code: exitUsage},
+		{name: "too many arguments", args: []string{"a", "b", "c"}, code: exitUsage},
+		{name: "invalid flag", args: []string{"-invalid", "a"}, code: exitUsage},
+		{name: "invalid format", args: []string{"-format", "xml", t.TempDir()}, code: exitUsage},
+		{name: "no domains", args: []string{t.TempDir()}, code: exitError},
+		{name: "nonexistent cert dir", args: []string{filepath.Join(t.TempDir(), "nonexistent")}, code: exitError},
+		{name: "nonexistent config", args: []string{"-config", "nonexistent.json", t.TempDir()}, code: exitError},
+		{name: "nonexistent domains.txt", args: []string{"-domains-txt", "nonexistent.txt", t.TempDir()}, code: exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			require.Equal(t, tt.code, runAnalyze(tt.args, &stdout, &stderr))
+			require.NotEmpty(t, stderr.String())
+		})
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]any
+		expected []string
+	}{
+		{
+			name:     "no errors",
+			metadata: map[string]any{"cert": map[string]any{"subject": "CN=a.example.com", "error": ""}},
+		},
+		{
+			name:     "error",
+			metadata: map[string]any{"cert": map[string]any{"error": "failed to read"}},
+			expected: []string{"cert: failed to read"},
+		},
+		{
+			name:     "errors",
+			metadata: map[string]any{"consistency": map[string]any{"errors": []any{"certificate could not be analyzed"}}},
+			expected: []string{"consistency: certificate could not be analyzed"},
+		},
+		{
+			name: "verification failure",
+			metadata: map[string]any{"verification": map[string]any{"valid": false, "errors": []any{
+				map[string]any{"reason": "expired", "message": "x509: certificate has expired"},
+				map[string]any{"reason": "unknown_authority"},
+				map[string]any{"message": "x509: unhandled critical extension"},
+			}}},
+			expected: []string{
+				"verification: expired: x509: certificate has expired",
+				"verification: unknown_authority",
+				"verification: x509: unhandled critical extension",
+			},
+		},
+		{
+			name: "list items",
+			metadata: map[string]any{"chain": map[string]any{"certificates": []any{
+				map[string]any{"position": float64(0)},
+				map[string]any{"error": "failed to parse"},
+			}}},
+			expected: []string{"chain.certificates[1]: failed to parse"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, collectErrors(tt.metadata, ""))
+		})
+	}
+}
+
+func TestRunCommand_Unknown(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, exitUsage, runCommand([]string{"unknown"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), `unknown command "unknown"`)
+}
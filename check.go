@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-openssl/internal"
+
+	"github.com/schumann-it/dehydrated-api-go/plugin/proto"
+)
+
+// checkState is the result of a monitoring check with the exit codes of the Nagios plugin API.
+type checkState int
+
+// States of the check command, in the order of their exit codes.
+const (
+	checkOK checkState = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+// Default thresholds of the check command in days before NotAfter.
+const (
+	defaultWarningDays  = int(internal.DefaultExpiringWindow / (24 * time.Hour))
+	defaultCriticalDays = 7
+)
+
+// String returns the name of the state as printed in the check output.
+func (s checkState) String() string {
+	switch s {
+	case checkOK:
+		return "OK"
+	case checkWarning:
+		return "WARNING"
+	case checkCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// severity orders the states for aggregation: a critical domain outweighs a warning, a warning an unknown domain.
+func (s checkState) severity() int {
+	switch s {
+	case checkCritical:
+		return 3
+	case checkWarning:
+		return 2
+	case checkUnknown:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// worse returns the more severe of both states.
+func (s checkState) worse(other checkState) checkState {
+	if other.severity() > s.severity() {
+		return other
+	}
+	return s
+}
+
+// checkThresholds are the days before NotAfter from which a certificate is reported as warning or critical.
+type checkThresholds struct {
+	warning  int
+	critical int
+}
+
+// domainCheck is the result of the check of a single domain.
+type domainCheck struct {
+	domain        string
+	state         checkState
+	messages      []string
+	daysRemaining *int // Whole days until NotAfter of cert.pem, nil if the certificate could not be analyzed
+}
+
+// runCheck implements the check command, which checks one or all domains of a certificate directory and reports
+// the result with the output format and exit codes of the Nagios plugin API.
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: check [options] <certDir> [domain]")
+		flags.PrintDefaults()
+	}
+	warning := flags.Int("warning", defaultWarningDays, "Days before expiry from which the state is WARNING")
+	critical := flags.Int("critical", defaultCriticalDays, "Days before expiry from which the state is CRITICAL")
+	configFile := flags.String("config", "", "JSON file with the plugin configuration")
+	if err := flags.Parse(args); err != nil {
+		return int(checkUnknown)
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return int(checkUnknown)
+	}
+	if *critical > *warning {
+		fmt.Fprintf(stdout, "UNKNOWN - critical threshold %d exceeds warning threshold %d\n", *critical, *warning)
+		return int(checkUnknown)
+	}
+
+	checks, err := checkDomains(flags.Arg(0), flags.Arg(1), *configFile, checkThresholds{*warning, *critical}, stderr)
+	if err != nil {
+		fmt.Fprintf(stdout, "UNKNOWN - %s\n", err)
+		return int(checkUnknown)
+	}
+
+	state := writeCheckResult(stdout, checks, checkThresholds{*warning, *critical})
+	return int(state)
+}
+
+// checkDomains checks the domain, or every domain directory in certDir if domain is empty.
+func checkDomains(certDir, domain, configFile string, thresholds checkThresholds, stderr io.Writer) ([]*domainCheck, error) {
+	plugin, err := newCLIPlugin(configFile, stderr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _, _ = plugin.Close(context.Background(), &proto.CloseRequest{}) }()
+
+	domains, err := selectDomains(certDir, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	checks := make([]*domainCheck, 0, len(domains))
+	for _, d := range domains {
+		checks = append(checks, plugin.checkDomain(filepath.Join(certDir, d), now, thresholds))
+	}
+	return checks, nil
+}
+
+// checkDomain checks the expiry of cert.pem, whether it matches privkey.pem and whether all files could be parsed.
+func (p *OpensslPlugin) checkDomain(domainDir string, now time.Time, thresholds checkThresholds) *domainCheck {
+	c := &domainCheck{domain: filepath.Base(domainDir)}
+	if _, err := os.Stat(domainDir); err != nil {
+		c.report(checkUnknown, fmt.Sprintf("domain directory does not exist: %s", domainDir))
+		return c
+	}
+
+	key := p.newKey(filepath.Join(domainDir, "privkey.pem"), now)
+	cert := p.newCertificate(filepath.Join(domainDir, "cert.pem"), now)
+	chain := p.newChain(filepath.Join(domainDir, "chain.pem"), now)
+	fullchain := p.newChain(filepath.Join(domainDir, "fullchain.pem"), now)
+
+	for _, err := range []string{key.Error, cert.Error, chain.Error, fullchain.Error} {
+		if err != "" {
+			c.report(checkCritical, err)
+		}
+	}
+
+	if cert.Validity != nil {
+		days := cert.Validity.DaysRemaining
+		c.daysRemaining = &days
+		switch {
+		case cert.Validity.Status == internal.ValidityStatusExpired:
+			c.report(checkCritical, fmt.Sprintf("certificate expired on %s", cert.NotAfter.Format(time.DateOnly)))
+		case days < thresholds.critical:
+			c.report(checkCritical, fmt.Sprintf("certificate expires in %d days", days))
+		case days < thresholds.warning:
+			c.report(checkWarning, fmt.Sprintf("certificate expires in %d days", days))
+		case cert.Validity.Status == internal.ValidityStatusNotYetValid:
+			c.report(checkWarning, fmt.Sprintf("certificate is not valid before %s", cert.NotBefore.Format(time.DateOnly)))
+		}
+	}
+
+	consistency := internal.NewConsistency(key, cert, chain, fullchain)
+	if consistency.KeyMatchesCertificate != nil && !*consistency.KeyMatchesCertificate {
+		c.report(checkCritical, "private key does not match certificate")
+	}
+	if consistency.FullchainLeafMatchesCert != nil && !*consistency.FullchainLeafMatchesCert {
+		c.report(checkWarning, "fullchain does not start with certificate")
+	}
+
+	if len(c.messages) == 0 && c.daysRemaining != nil {
+		c.messages = append(c.messages, fmt.Sprintf("certificate expires in %d days", *c.daysRemaining))
+	}
+	return c
+}
+
+// report adds a message and raises the state of the domain.
+func (c *domainCheck) report(state checkState, message string) {
+	c.state = c.state.worse(state)
+	c.messages = append(c.messages, message)
+}
+
+// writeCheckResult writes the status line with the perfdata of the days remaining, followed by one line per domain
+// from the most to the least severe state, and returns the overall state.
+func writeCheckResult(w io.Writer, checks []*domainCheck, thresholds checkThresholds) checkState {
+	state := checkOK
+	counts := map[checkState]int{}
+	var perfdata []string
+	for _, c := range checks {
+		state = state.worse(c.state)
+		counts[c.state]++
+		if c.daysRemaining != nil {
+			// Ranges ending with a colon alert below the threshold
+			perfdata = append(perfdata, fmt.Sprintf("'%s'=%d;%d:;%d:", c.domain, *c.daysRemaining,
+				thresholds.warning, thresholds.critical))
+		}
+	}
+
+	var summary []string
+	for _, s := range []checkState{checkCritical, checkWarning, checkUnknown, checkOK} {
+		if counts[s] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[s], strings.ToLower(s.String())))
+		}
+	}
+	fmt.Fprintf(w, "%s - %s", state, strings.Join(summary, ", "))
+	if len(perfdata) > 0 {
+		fmt.Fprintf(w, " | %s", strings.Join(perfdata, " "))
+	}
+	fmt.Fprintln(w)
+
+	for _, s := range []checkState{checkCritical, checkWarning, checkUnknown, checkOK} {
+		for _, c := range checks {
+			if c.state == s {
+				fmt.Fprintf(w, "%s: %s: %s\n", s, c.domain, strings.Join(c.messages, "; "))
+			}
+		}
+	}
+	return state
+}
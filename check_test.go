@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCheck_OK(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+
+	var stdout, stderr bytes.Buffer
+	code := runCheck([]string{certDir}, &stdout, &stderr)
+	require.Equal(t, int(checkOK), code, stdout.String())
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Equal(t, "OK - 1 ok | 'a.example.com'=59;30:;7:", lines[0])
+	require.Equal(t, "OK: a.example.com: certificate expires in 59 days", lines[1])
+}
+
+func TestRunCheck_Thresholds(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	writeTestDomain(t, certDir, "b.example.com", time.Now().Add(20*24*time.Hour))
+	writeTestDomain(t, certDir, "c.example.com", time.Now().Add(3*24*time.Hour))
+
+	var stdout, stderr bytes.Buffer
+	code := runCheck([]string{certDir}, &stdout, &stderr)
+	require.Equal(t, int(checkCritical), code, stdout.String())
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "CRITICAL - 1 critical, 1 warning, 1 ok | "+
+		"'a.example.com'=59;30:;7: 'b.example.com'=19;30:;7: 'c.example.com'=2;30:;7:", lines[0])
+	require.Equal(t, "CRITICAL: c.example.com: certificate expires in 2 days", lines[1])
+	require.Equal(t, "WARNING: b.example.com: certificate expires in 19 days", lines[2])
+	require.Equal(t, "OK: a.example.com: certificate expires in 59 days", lines[3])
+
+	// Custom thresholds
+	stdout.Reset()
+	code = runCheck([]string{"-warning", "10", "-critical", "1", certDir, "b.example.com"}, &stdout, &stderr)
+	require.Equal(t, int(checkOK), code, stdout.String())
+	require.Contains(t, stdout.String(), "'b.example.com'=19;10:;1:")
+}
+
+func TestRunCheck_Expired(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(-time.Minute))
+
+	var stdout, stderr bytes.Buffer
+	code := runCheck([]string{certDir}, &stdout, &stderr)
+	require.Equal(t, int(checkCritical), code, stdout.String())
+	require.Contains(t, stdout.String(), "CRITICAL: a.example.com: certificate expired on")
+}
+
+func TestRunCheck_KeyMismatch(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	writeTestDomain(t, certDir, "b.example.com", time.Now().Add(60*24*time.Hour))
+	otherKey, err := os.ReadFile(filepath.Join(certDir, "b.example.com", "privkey.pem"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(certDir, "a.example.com", "privkey.pem"), otherKey, 0600))
+
+	var stdout, stderr bytes.Buffer
+	code := runCheck([]string{certDir, "a.example.com"}, &stdout, &stderr)
+	require.Equal(t, int(checkCritical), code, stdout.String())
+	require.Contains(t, stdout.String(), "CRITICAL: a.example.com: private key does not match certificate")
+}
+
+func TestRunCheck_ParseError(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+	require.NoError(t, os.WriteFile(filepath.Join(certDir, "a.example.com", "cert.pem"), []byte("invalid"), 0600))
+
+	var stdout, stderr bytes.Buffer
+	code := runCheck([]string{certDir}, &stdout, &stderr)
+	require.Equal(t, int(checkCritical), code, stdout.String())
+	require.Equal(t, "CRITICAL - 1 critical", strings.Split(stdout.String(), "\n")[0])
+	require.Contains(t, stdout.String(), "failed to decode PEM block")
+}
+
+func TestRunCheck_Unknown(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomain(t, certDir, "a.example.com", time.Now().Add(60*24*time.Hour))
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+	}{
+		{name: "missing domain", args: []string{certDir, "nonexistent"}, output: "UNKNOWN - 1 unknown"},
+		{name: "no domains", args: []string{t.TempDir()}, output: "UNKNOWN - no domain directories"},
+		{name: "invalid thresholds", args: []string{"-warning", "5", "-critical", "10", certDir}, output: "UNKNOWN - critical threshold"},
+		{name: "missing cert dir", args: []string{}},
+		{name: "invalid flag", args: []string{"-invalid", certDir}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			require.Equal(t, int(checkUnknown), runCheck(tt.args, &stdout, &stderr))
+			if tt.output != "" {
+				require.True(t, strings.HasPrefix(stdout.String(), tt.output), stdout.String())
+			}
+		})
+	}
+}
+
+func TestCheckState_Worse(t *testing.T) {
+	require.Equal(t, checkWarning, checkOK.worse(checkWarning))
+	require.Equal(t, checkWarning, checkWarning.worse(checkUnknown))
+	require.Equal(t, checkCritical, checkUnknown.worse(checkCritical))
+	require.Equal(t, checkUnknown, checkOK.worse(checkUnknown))
+}
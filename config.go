@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-openssl/internal"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxSuggestionDistance is the largest edit distance between an unknown and a known option for which the known
+// option is suggested as the intended one.
+const maxSuggestionDistance = 2
+
+// Config is the plugin configuration passed to Initialize. The JSON names are the option names.
+type Config struct {
+	LogLevel          string   `json:"logLevel"`          // hclog level name, empty keeps the current level
+	ExpiringDays      int      `json:"expiringDays"`      // Days before NotAfter in which certificates are expiring
+	CABundle          string   `json:"caBundle"`          // PEM file with the roots used instead of the system roots
+	KeyPassphrase     string   `json:"keyPassphrase"`     // Passphrase of encrypted private keys
+	KeyPassphraseFile string   `json:"keyPassphraseFile"` // File containing the passphrase of encrypted private keys
+	KeyMaxMode        FileMode `json:"keyMaxMode"`        // Most permissive private key file mode without a warning
+	KeyBlocklist      string   `json:"keyBlocklist"`      // File with SHA-256 SPKI fingerprints of compromised keys
+	CacheSize         int      `json:"cacheSize"`         // Maximum number of cached analyses, 0 disables the cache
+	CacheTTL          Duration `json:"cacheTTL"`          // Maximum age of a cached analysis
+	Watch             bool     `json:"watch"`             // Whether to watch the certificate directory
+	CertDir           string   `json:"certDir"`           // Certificate directory to watch from initialization on
+	MetricsAddress    string   `json:"metricsAddress"`    // Address of the metrics endpoint, empty disables it
+	Accounts          bool     `json:"accounts"`          // Whether to report dehydrated's ACME accounts
+}
+
+// DefaultConfig returns the configuration used for options that are not set.
+func DefaultConfig() *Config {
+	return &Config{
+		ExpiringDays: int(internal.DefaultExpiringWindow / (24 * time.Hour)),
+		KeyMaxMode:   FileMode(internal.DefaultKeyMaxMode),
+		CacheSize:    internal.DefaultCacheSize,
+		CacheTTL:     Duration(internal.DefaultCacheTTL),
+	}
+}
+
+// ParseConfig decodes the options of an InitializeRequest over the defaults and validates the result.
+// Unknown options and values of the wrong type are rejected.
+func ParseConfig(values map[string]*structpb.Value) (*Config, error) {
+	c := DefaultConfig()
+
+	known := c.optionNames()
+	options := make(map[string]any, len(values))
+	for name, value := range values {
+		if !known[name] {
+			return nil, unknownOptionError(name, known)
+		}
+		options[name] = value.AsInterface()
+	}
+
+	// Options are decoded one by one, so an error names the option
+	for name, value := range options {
+		b, err := json.Marshal(map[string]any{name: value})
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if err = json.Unmarshal(b, c); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return nil, fmt.Errorf("invalid %s: expected %s, got %s", name, typeName(typeErr.Type), typeErr.Value)
+			}
+			return nil, fmt.Errorf("invalid %s %v: %w", name, value, err)
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the values of the options that are not restricted by their type.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.LogLevel != "" && hclog.LevelFromString(c.LogLevel) == hclog.NoLevel {
+		errs = append(errs, fmt.Errorf("invalid logLevel %q: expected trace, debug, info, warn, error or off", c.LogLevel))
+	}
+	if c.ExpiringDays < 0 {
+		errs = append(errs, fmt.Errorf("invalid expiringDays %d: must not be negative", c.ExpiringDays))
+	}
+	if c.CacheSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid cacheSize %d: must not be negative", c.CacheSize))
+	}
+	if c.CacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("invalid cacheTTL %q: must be positive", c.CacheTTL))
+	}
+	if c.KeyPassphrase != "" && c.KeyPassphraseFile != "" {
+		errs = append(errs, errors.New("keyPassphrase and keyPassphraseFile are mutually exclusive"))
+	}
+	if c.CertDir != "" && !c.Watch {
+		errs = append(errs, errors.New("certDir is only used with watch enabled"))
+	}
+	if c.MetricsAddress != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddress); err != nil {
+			errs = append(errs, fmt.Errorf("invalid metricsAddress %q: %w", c.MetricsAddress, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogArgs returns the options as key-value pairs for the logger, with the passphrase redacted.
+func (c *Config) LogArgs() []any {
+	redacted := *c
+	if redacted.KeyPassphrase != "" {
+		redacted.KeyPassphrase = "[redacted]"
+	}
+
+	v := reflect.ValueOf(redacted)
+	args := make([]any, 0, 2*v.NumField())
+	for i := range v.NumField() {
+		args = append(args, optionName(v.Type().Field(i)), fmt.Sprint(v.Field(i).Interface()))
+	}
+	return args
+}
+
+// optionNames returns the names of all options.
+func (c *Config) optionNames() map[string]bool {
+	t := reflect.TypeOf(*c)
+	names := make(map[string]bool, t.NumField())
+	for i := range t.NumField() {
+		names[optionName(t.Field(i))] = true
+	}
+	return names
+}
+
+// optionName returns the option name of a Config field.
+func optionName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// unknownOptionError reports an unknown option, suggesting the known option it was probably meant to be.
+func unknownOptionError(name string, known map[string]bool) error {
+	best, bestDistance := "", maxSuggestionDistance+1
+	names := make([]string, 0, len(known))
+	for option := range known {
+		names = append(names, option)
+	}
+	sort.Strings(names)
+	for _, option := range names {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(option)); d < bestDistance {
+			best, bestDistance = option, d
+		}
+	}
+
+	if best != "" {
+		return fmt.Errorf("unknown option %q, did you mean %q?", name, best)
+	}
+	return fmt.Errorf("unknown option %q, known options are %s", name, strings.Join(names, ", "))
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// typeName describes the JSON type expected for a Go type in error messages.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	default:
+		return "string"
+	}
+}
+
+// FileMode is a file mode option given as octal string, e.g. "0600". An empty string keeps the default.
+type FileMode os.FileMode
+
+// UnmarshalJSON parses the octal string.
+func (m *FileMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil || s == "" {
+		return err
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return fmt.Errorf("expected an octal file mode such as 0600, got %q", s)
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// String formats the mode as octal number.
+func (m FileMode) String() string {
+	return fmt.Sprintf("%04o", uint32(m))
+}
+
+// Duration is a duration option given as Go duration string, e.g. "30m". An empty string keeps the default.
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil || s == "" {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("expected a duration such as 30m, got %q", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// String formats the duration like time.Duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/schumann-it/dehydrated-api-metadata-plugin-openssl/internal"
+)
+
+func TestParseConfig_Defaults(t *testing.T) {
+	cfg, err := ParseConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, DefaultConfig(), cfg)
+	require.Equal(t, 30, cfg.ExpiringDays)
+	require.Equal(t, FileMode(internal.DefaultKeyMaxMode), cfg.KeyMaxMode)
+	require.Equal(t, internal.DefaultCacheSize, cfg.CacheSize)
+	require.Equal(t, Duration(internal.DefaultCacheTTL), cfg.CacheTTL)
+}
+
+func TestParseConfig_Values(t *testing.T) {
+	cfg, err := ParseConfig(map[string]*structpb.Value{
+		"logLevel":       structpb.NewStringValue("debug"),
+		"expiringDays":   structpb.NewNumberValue(14),
+		"keyPassphrase":  structpb.NewStringValue("secret"),
+		"keyMaxMode":     structpb.NewStringValue("0640"),
+		"cacheSize":      structpb.NewNumberValue(0),
+		"cacheTTL":       structpb.NewStringValue("30m"),
+		"watch":          structpb.NewBoolValue(true),
+		"certDir":        structpb.NewStringValue("/etc/dehydrated/certs"),
+		"metricsAddress": structpb.NewStringValue(":9110"),
+		"accounts":       structpb.NewBoolValue(true),
+	})
+	require.NoError(t, err)
+	require.Equal(t, &Config{
+		LogLevel:       "debug",
+		ExpiringDays:   14,
+		KeyPassphrase:  "secret",
+		KeyMaxMode:     FileMode(0640),
+		CacheSize:      0,
+		CacheTTL:       Duration(30 * time.Minute),
+		Watch:          true,
+		CertDir:        "/etc/dehydrated/certs",
+		MetricsAddress: ":9110",
+		Accounts:       true,
+	}, cfg)
+}
+
+func TestParseConfig_EmptyStringsKeepDefaults(t *testing.T) {
+	cfg, err := ParseConfig(map[string]*structpb.Value{
+		"keyMaxMode": structpb.NewStringValue(""),
+		"cacheTTL":   structpb.NewStringValue(""),
+	})
+	require.NoError(t, err)
+	require.Equal(t, DefaultConfig(), cfg)
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]*structpb.Value
+		err    string
+	}{
+		{
+			name:   "typo",
+			config: map[string]*structpb.Value{"expiringDay": structpb.NewNumberValue(14)},
+			err:    `unknown option "expiringDay", did you mean "expiringDays"?`,
+		},
+		{
+			name:   "wrong case",
+			config: map[string]*structpb.Value{"cabundle": structpb.NewStringValue("ca.pem")},
+			err:    `unknown option "cabundle", did you mean "caBundle"?`,
+		},
+		{
+			name:   "unknown option",
+			config: map[string]*structpb.Value{"renewDays": structpb.NewNumberValue(14)},
+			err:    `unknown option "renewDays", known options are accounts, caBundle`,
+		},
+		{
+			name:   "string for number",
+			config: map[string]*structpb.Value{"expiringDays": structpb.NewStringValue("14")},
+			err:    "invalid expiringDays: expected integer, got string",
+		},
+		{
+			name:   "fraction for number",
+			config: map[string]*structpb.Value{"cacheSize": structpb.NewNumberValue(1.5)},
+			err:    "invalid cacheSize: expected integer, got number 1.5",
+		},
+		{
+			name:   "string for boolean",
+			config: map[string]*structpb.Value{"watch": structpb.NewStringValue("yes")},
+			err:    "invalid watch: expected boolean, got string",
+		},
+		{
+			name:   "number for file mode",
+			config: map[string]*structpb.Value{"keyMaxMode": structpb.NewNumberValue(600)},
+			err:    "invalid keyMaxMode: expected string, got number",
+		},
+		{
+			name:   "invalid file mode",
+			config: map[string]*structpb.Value{"keyMaxMode": structpb.NewStringValue("01777")},
+			err:    "invalid keyMaxMode 01777: expected an octal file mode",
+		},
+		{
+			name:   "invalid duration",
+			config: map[string]*structpb.Value{"cacheTTL": structpb.NewStringValue("one hour")},
+			err:    "invalid cacheTTL one hour: expected a duration such as 30m",
+		},
+		{
+			name:   "negative days",
+			config: map[string]*structpb.Value{"expiringDays": structpb.NewNumberValue(-1)},
+			err:    "invalid expiringDays -1: must not be negative",
+		},
+		{
+			name:   "negative cache size",
+			config: map[string]*structpb.Value{"cacheSize": structpb.NewNumberValue(-1)},
+			err:    "invalid cacheSize -1: must not be negative",
+		},
+		{
+			name:   "negative duration",
+			config: map[string]*structpb.Value{"cacheTTL": structpb.NewStringValue("-1h")},
+			err:    `invalid cacheTTL "-1h0m0s": must be positive`,
+		},
+		{
+			name:   "invalid log level",
+			config: map[string]*structpb.Value{"logLevel": structpb.NewStringValue("verbose")},
+			err:    `invalid logLevel "verbose"`,
+		},
+		{
+			name: "passphrase and passphrase file",
+			config: map[string]*structpb.Value{
+				"keyPassphrase":     structpb.NewStringValue("secret"),
+				"keyPassphraseFile": structpb.NewStringValue("passphrase.txt"),
+			},
+			err: "keyPassphrase and keyPassphraseFile are mutually exclusive",
+		},
+		{
+			name:   "cert dir without watch",
+			config: map[string]*structpb.Value{"certDir": structpb.NewStringValue("/etc/dehydrated/certs")},
+			err:    "certDir is only used with watch enabled",
+		},
+		{
+			name:   "invalid metrics address",
+			config: map[string]*structpb.Value{"metricsAddress": structpb.NewStringValue("9110")},
+			err:    `invalid metricsAddress "9110"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig(tt.config)
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseConfig_MultipleErrors(t *testing.T) {
+	_, err := ParseConfig(map[string]*structpb.Value{
+		"expiringDays": structpb.NewNumberValue(-1),
+		"cacheSize":    structpb.NewNumberValue(-1),
+	})
+	require.ErrorContains(t, err, "invalid expiringDays")
+	require.ErrorContains(t, err, "invalid cacheSize")
+}
+
+func TestConfig_LogArgs(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.KeyPassphrase = "secret"
+
+	args := cfg.LogArgs()
+	require.Len(t, args, 26)
+
+	values := map[any]any{}
+	for i := 0; i < len(args); i += 2 {
+		values[args[i]] = args[i+1]
+	}
+	require.Equal(t, "[redacted]", values["keyPassphrase"])
+	require.Equal(t, "0600", values["keyMaxMode"])
+	require.Equal(t, "1h0m0s", values["cacheTTL"])
+	require.Equal(t, "30", values["expiringDays"])
+	require.Equal(t, "secret", cfg.KeyPassphrase)
+}
+
+func TestEditDistance(t *testing.T) {
+	require.Equal(t, 0, editDistance("caBundle", "caBundle"))
+	require.Equal(t, 1, editDistance("expiringDay", "expiringDays"))
+	require.Equal(t, 2, editDistance("cacheTtl", "cacheTTL"))
+	require.Equal(t, 3, editDistance("", "abc"))
+}
+
+func TestFileMode_String(t *testing.T) {
+	require.Equal(t, "0640", FileMode(os.FileMode(0640)).String())
+}
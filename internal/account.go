@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Accounts lists the ACME accounts dehydrated keeps in its accounts directory, one subdirectory per CA.
+type Accounts struct {
+	Dir      string     `json:"dir"`             // Path to the accounts directory
+	Accounts []*Account `json:"accounts"`        // Accounts ordered by directory name
+	Error    string     `json:"error,omitempty"` // Error represents any error encountered during accounts analysis.
+
+	loadKey func(file string) *Key // Analyzes the account keys, NewKey unless set by WithKeyLoader
+}
+
+// Account describes a single ACME account. The directory name is the base64url encoded CA directory URL.
+type Account struct {
+	Directory  string   `json:"directory"`             // Name of the account directory
+	CAURL      string   `json:"ca_url,omitempty"`      // CA directory URL decoded from the directory name
+	AccountID  string   `json:"account_id,omitempty"`  // Account ID from account_id.json or registration_info.json
+	AccountURL string   `json:"account_url,omitempty"` // Account URL, if the CA uses the URL as account ID
+	Contacts   []string `json:"contacts,omitempty"`    // Contact addresses, with the mailto: scheme removed
+	Status     string   `json:"status,omitempty"`      // Account status, e.g. valid or deactivated
+	Key        *Key     `json:"key,omitempty"`         // Analysis of account_key.pem
+	Errors     []string `json:"errors,omitempty"`      // Errors encountered while analyzing the files of this account
+}
+
+// registrationInfo holds the fields of registration_info.json and account_id.json.
+// ACME v1 CAs return a numeric id, so the id is kept as any.
+type registrationInfo struct {
+	ID      any      `json:"id"`
+	Contact []string `json:"contact"`
+	Status  string   `json:"status"`
+}
+
+// AccountsOption configures the analysis of Accounts.
+type AccountsOption func(*Accounts)
+
+// WithKeyLoader analyzes the account keys with load instead of NewKey, e.g. to take them from a cache.
+func WithKeyLoader(load func(file string) *Key) AccountsOption {
+	return func(a *Accounts) {
+		a.loadKey = load
+	}
+}
+
+// NewAccounts creates a new Accounts instance by scanning the accounts directory.
+func NewAccounts(dir string, opts ...AccountsOption) *Accounts {
+	a := &Accounts{
+		Dir:      dir,
+		Accounts: []*Account{}, // Always initialize to empty slice
+		loadKey:  func(file string) *Key { return NewKey(file) },
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	err := a.analyze()
+	if err != nil {
+		a.Error = err.Error()
+	}
+
+	return a
+}
+
+// analyze analyzes every subdirectory of the accounts directory.
+func (a *Accounts) analyze() error {
+	if a.Dir == "" {
+		return errors.New("accounts directory not configured")
+	}
+	entries, err := os.ReadDir(a.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", a.Dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		a.Accounts = append(a.Accounts, newAccount(filepath.Join(a.Dir, entry.Name()), a.loadKey))
+	}
+	sort.Slice(a.Accounts, func(i, j int) bool {
+		return a.Accounts[i].Directory < a.Accounts[j].Directory
+	})
+
+	return nil
+}
+
+// NewAccount creates a new Account from an account directory.
+func NewAccount(dir string) *Account {
+	return newAccount(dir, func(file string) *Key { return NewKey(file) })
+}
+
+// newAccount creates a new Account from an account directory, analyzing the account key with loadKey.
+func newAccount(dir string, loadKey func(file string) *Key) *Account {
+	a := &Account{
+		Directory: filepath.Base(dir),
+	}
+
+	if caURL, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(a.Directory, "=")); err == nil {
+		// dehydrated encodes the output of echo, including its trailing newline
+		a.CAURL = strings.TrimRightFunc(string(caURL), unicode.IsSpace)
+	} else {
+		a.Errors = append(a.Errors, fmt.Sprintf("failed to decode CA URL from %s: %s", a.Directory, err))
+	}
+
+	a.Key = loadKey(filepath.Join(dir, "account_key.pem"))
+	if a.Key.Error != "" {
+		a.Errors = append(a.Errors, a.Key.Error)
+	}
+
+	info, err := readRegistrationInfo(filepath.Join(dir, "registration_info.json"))
+	if err != nil {
+		a.Errors = append(a.Errors, err.Error())
+	} else {
+		a.Status = info.Status
+		a.AccountID = formatAccountID(info.ID)
+		for _, contact := range info.Contact {
+			a.Contacts = append(a.Contacts, strings.TrimPrefix(contact, "mailto:"))
+		}
+	}
+
+	// Newer dehydrated versions store the account ID separately
+	idFile := filepath.Join(dir, "account_id.json")
+	if _, statErr := os.Stat(idFile); statErr == nil {
+		id, readErr := readRegistrationInfo(idFile)
+		if readErr != nil {
+			a.Errors = append(a.Errors, readErr.Error())
+		} else if formatAccountID(id.ID) != "" {
+			a.AccountID = formatAccountID(id.ID)
+		}
+	}
+
+	if u, parseErr := url.Parse(a.AccountID); parseErr == nil && u.IsAbs() {
+		a.AccountURL = a.AccountID
+	}
+
+	return a
+}
+
+// readRegistrationInfo reads and decodes a JSON file of an account directory.
+func readRegistrationInfo(file string) (*registrationInfo, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	info := &registrationInfo{}
+	if err = json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return info, nil
+}
+
+// formatAccountID formats a string or numeric account ID.
+func formatAccountID(id any) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCAURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+// writeTestAccount creates an account directory for the CA URL with the given files. Like dehydrated, the
+// directory name encodes the CA URL followed by a newline.
+func writeTestAccount(t *testing.T, accountsDir, caURL string, files map[string][]byte) string {
+	t.Helper()
+	dir := filepath.Join(accountsDir, base64.RawURLEncoding.EncodeToString([]byte(caURL+"\n")))
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	for name, content := range files {
+		writeTestFile(t, dir, name, content)
+	}
+	return dir
+}
+
+func TestNewAccounts(t *testing.T) {
+	accountsDir := t.TempDir()
+	writeTestAccount(t, accountsDir, testCAURL, map[string][]byte{
+		"account_key.pem": pemEncodeKey(t, newTestKey(t)),
+		"registration_info.json": []byte(`{"key":{"kty":"EC"},"contact":["mailto:admin@example.com"],` +
+			`"status":"valid","createdAt":"2024-01-01T00:00:00Z"}`),
+		"account_id.json": []byte(`{"id":"https://acme-v02.api.letsencrypt.org/acme/acct/12345"}`),
+	})
+	writeTestFile(t, accountsDir, "README", []byte("not an account"))
+
+	accounts := NewAccounts(accountsDir)
+	require.Empty(t, accounts.Error)
+	require.Len(t, accounts.Accounts, 1)
+
+	account := accounts.Accounts[0]
+	require.Empty(t, account.Errors)
+	require.Equal(t, "aHR0cHM6Ly9hY21lLXYwMi5hcGkubGV0c2VuY3J5cHQub3JnL2RpcmVjdG9yeQo", account.Directory)
+	require.Equal(t, testCAURL, account.CAURL)
+	require.Equal(t, "https://acme-v02.api.letsencrypt.org/acme/acct/12345", account.AccountID)
+	require.Equal(t, account.AccountID, account.AccountURL)
+	require.Equal(t, []string{"admin@example.com"}, account.Contacts)
+	require.Equal(t, "valid", account.Status)
+	require.Equal(t, KeyTypeECDSA, account.Key.Type)
+	require.Equal(t, 256, account.Key.Size)
+}
+
+func TestNewAccounts_WithKeyLoader(t *testing.T) {
+	accountsDir := t.TempDir()
+	dir := writeTestAccount(t, accountsDir, testCAURL, map[string][]byte{"account_key.pem": pemEncodeKey(t, newTestKey(t))})
+
+	var loaded []string
+	accounts := NewAccounts(accountsDir, WithKeyLoader(func(file string) *Key {
+		loaded = append(loaded, file)
+		return NewKey(file)
+	}))
+	require.Len(t, accounts.Accounts, 1)
+	require.Equal(t, []string{filepath.Join(dir, "account_key.pem")}, loaded)
+	require.Equal(t, KeyTypeECDSA, accounts.Accounts[0].Key.Type)
+}
+
+func TestNewAccount_NumericID(t *testing.T) {
+	dir := writeTestAccount(t, t.TempDir(), "https://acme-v01.api.letsencrypt.org/directory", map[string][]byte{
+		"account_key.pem":        pemEncodeKey(t, newTestKey(t)),
+		"registration_info.json": []byte(`{"id":12345678,"contact":["mailto:a@example.com","tel:+1"],"status":"valid"}`),
+	})
+
+	account := NewAccount(dir)
+	require.Empty(t, account.Errors)
+	require.Equal(t, "https://acme-v01.api.letsencrypt.org/directory", account.CAURL)
+	require.Equal(t, "12345678", account.AccountID)
+	require.Empty(t, account.AccountURL)
+	require.Equal(t, []string{"a@example.com", "tel:+1"}, account.Contacts)
+}
+
+func TestNewAccount_InvalidFiles(t *testing.T) {
+	accountsDir := t.TempDir()
+	dir := filepath.Join(accountsDir, "not*base64")
+	require.NoError(t, os.Mkdir(dir, 0700))
+	writeTestFile(t, dir, "registration_info.json", []byte("{invalid"))
+	writeTestFile(t, dir, "account_id.json", []byte("[]"))
+
+	account := NewAccount(dir)
+	require.Empty(t, account.CAURL)
+	require.Len(t, account.Errors, 4)
+	require.Contains(t, account.Errors[0], "failed to decode CA URL")
+	require.Contains(t, account.Errors[1], "failed to read")
+	require.Contains(t, account.Errors[2], "failed to parse")
+	require.Contains(t, account.Errors[3], "failed to parse")
+}
+
+func TestNewAccounts_InvalidDir(t *testing.T) {
+	accounts := NewAccounts("")
+	require.Equal(t, "accounts directory not configured", accounts.Error)
+	require.Empty(t, accounts.Accounts)
+
+	accounts = NewAccounts("nonexistent")
+	require.Contains(t, accounts.Error, "failed to read")
+}
+
+func TestFormatAccountID(t *testing.T) {
+	require.Empty(t, formatAccountID(nil))
+	require.Equal(t, "abc", formatAccountID("abc"))
+	require.Equal(t, "123456789012", formatAccountID(float64(123456789012)))
+	require.Equal(t, "true", formatAccountID(true))
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// certConfigAssignment matches a plain shell variable assignment, optionally prefixed with export.
+var certConfigAssignment = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// keyAlgoCurves maps the KEY_ALGO values dehydrated accepts for elliptic curve keys to the curve names of Key.Curve.
+var keyAlgoCurves = map[string]string{
+	"prime256v1": "P-256",
+	"secp384r1":  "P-384",
+	"secp521r1":  "P-521",
+}
+
+// CertConfig represents the per-certificate config file dehydrated sources from the domain directory.
+// The file is parsed with a restricted parser that only accepts literal assignments; nothing is executed.
+type CertConfig struct {
+	File       string            `json:"file"`                 // Path to the config file
+	Overrides  map[string]string `json:"overrides"`            // Variables assigned in the file, e.g. KEY_ALGO
+	Ignored    []string          `json:"ignored,omitempty"`    // Lines that are not literal assignments and were skipped
+	Mismatches []string          `json:"mismatches,omitempty"` // Overrides that disagree with the files on disk, see Compare
+	Error      string            `json:"error,omitempty"`      // Error represents any error encountered during config analysis.
+}
+
+// NewCertConfig creates a new CertConfig instance from the provided file path and parses its assignments.
+func NewCertConfig(file string) *CertConfig {
+	c := &CertConfig{
+		File:      file,
+		Overrides: map[string]string{}, // Always initialize to empty map
+	}
+	err := c.analyze()
+	if err != nil {
+		c.Error = err.Error()
+	}
+
+	return c
+}
+
+// analyze reads the config file and records every literal assignment.
+func (c *CertConfig) analyze() error {
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", c.File, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := certConfigAssignment.FindStringSubmatch(line)
+		if m == nil {
+			c.Ignored = append(c.Ignored, fmt.Sprintf("line %d: %s", n, line))
+			continue
+		}
+		value, ok := parseShellValue(m[2])
+		if !ok {
+			c.Ignored = append(c.Ignored, fmt.Sprintf("line %d: %s", n, line))
+			continue
+		}
+		c.Overrides[m[1]] = value
+	}
+
+	return scanner.Err()
+}
+
+// parseShellValue returns the literal value of the right-hand side of a shell assignment.
+// Values that would need expansion, substitution or further commands are rejected.
+func parseShellValue(raw string) (string, bool) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 || !isTrailingComment(raw[end+2:]) {
+			return "", false
+		}
+		return raw[1 : end+1], true
+	case strings.HasPrefix(raw, `"`):
+		end := strings.Index(raw[1:], `"`)
+		if end < 0 || !isTrailingComment(raw[end+2:]) {
+			return "", false
+		}
+		value := raw[1 : end+1]
+		if strings.ContainsAny(value, "$`\\") {
+			return "", false
+		}
+		return value, true
+	default:
+		value, rest, _ := strings.Cut(raw, " ")
+		if !isTrailingComment(rest) || strings.ContainsAny(value, "$`\\\"';&|<>(){}") {
+			return "", false
+		}
+		return value, true
+	}
+}
+
+// isTrailingComment reports whether the text following a value is empty or a comment.
+func isTrailingComment(rest string) bool {
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// Compare checks the KEY_ALGO, KEYSIZE and PREFERRED_CHAIN overrides against the private key and the chain.
+// Overrides whose counterpart could not be analyzed are not checked.
+func (c *CertConfig) Compare(key *Key, chain *Chain) {
+	if algo, ok := c.Overrides["KEY_ALGO"]; ok && key.Error == "" {
+		c.compareKeyAlgo(algo, key)
+	}
+
+	if size, ok := c.Overrides["KEYSIZE"]; ok && key.Error == "" && key.Type == KeyTypeRSA {
+		if bits, err := strconv.Atoi(size); err != nil {
+			c.Mismatches = append(c.Mismatches, fmt.Sprintf("KEYSIZE=%s is not a number", size))
+		} else if bits != key.Size {
+			c.Mismatches = append(c.Mismatches, fmt.Sprintf("KEYSIZE=%d but the key has %d bits", bits, key.Size))
+		}
+	}
+
+	if preferred, ok := c.Overrides["PREFERRED_CHAIN"]; ok && preferred != "" && len(chain.Certificates) > 0 {
+		top := chain.Certificates[len(chain.Certificates)-1].X509()
+		if top != nil && top.Issuer.CommonName != preferred {
+			c.Mismatches = append(c.Mismatches,
+				fmt.Sprintf("PREFERRED_CHAIN=%s but the chain is issued by %s", preferred, top.Issuer.CommonName))
+		}
+	}
+}
+
+// compareKeyAlgo records a mismatch if the key does not have the type and curve selected by KEY_ALGO.
+func (c *CertConfig) compareKeyAlgo(algo string, key *Key) {
+	if algo == KeyTypeRSA {
+		if key.Type != KeyTypeRSA {
+			c.Mismatches = append(c.Mismatches, fmt.Sprintf("KEY_ALGO=%s but the key is %s", algo, describeKeyType(key)))
+		}
+		return
+	}
+
+	curve, known := keyAlgoCurves[algo]
+	if !known {
+		c.Mismatches = append(c.Mismatches, fmt.Sprintf("KEY_ALGO=%s is not supported by dehydrated", algo))
+		return
+	}
+	if key.Type != KeyTypeECDSA || key.Curve != curve {
+		c.Mismatches = append(c.Mismatches, fmt.Sprintf("KEY_ALGO=%s but the key is %s", algo, describeKeyType(key)))
+	}
+}
+
+// describeKeyType returns the key type together with its curve, if any, e.g. "ecdsa P-384".
+func describeKeyType(key *Key) string {
+	if key.Curve == "" {
+		return key.Type
+	}
+	return key.Type + " " + key.Curve
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCertConfig(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "config", []byte(`# per-certificate overrides
+KEY_ALGO=secp384r1
+export KEYSIZE="4096"
+CHALLENGETYPE='dns-01' # use the dns hook
+RENEW_DAYS=14
+PREFERRED_CHAIN="ISRG Root X1"
+HOOK=${BASEDIR}/hook.sh
+CONTACT_EMAIL="$(whoami)@example.com"
+WELLKNOWN=/var/www/acme; rm -rf /
+source /etc/dehydrated/extra
+`))
+
+	cfg := NewCertConfig(file)
+	require.Empty(t, cfg.Error)
+	require.Equal(t, map[string]string{
+		"KEY_ALGO":        "secp384r1",
+		"KEYSIZE":         "4096",
+		"CHALLENGETYPE":   "dns-01",
+		"RENEW_DAYS":      "14",
+		"PREFERRED_CHAIN": "ISRG Root X1",
+	}, cfg.Overrides)
+	require.Len(t, cfg.Ignored, 4)
+	require.Contains(t, cfg.Ignored[0], "line 7")
+}
+
+func TestNewCertConfig_NonExistentFile(t *testing.T) {
+	cfg := NewCertConfig("nonexistent")
+	require.Contains(t, cfg.Error, "failed to read")
+	require.Empty(t, cfg.Overrides)
+}
+
+func TestParseShellValue(t *testing.T) {
+	testCases := []struct {
+		raw      string
+		expected string
+		ok       bool
+	}{
+		{"rsa", "rsa", true},
+		{"", "", true},
+		{"rsa # comment", "rsa", true},
+		{`"ISRG Root X1"`, "ISRG Root X1", true},
+		{`'$literal'`, "$literal", true},
+		{`"$HOME"`, "", false},
+		{"`id`", "", false},
+		{`"unterminated`, "", false},
+		{`"a" b`, "", false},
+		{"a b", "", false},
+		{"a;b", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.raw, func(t *testing.T) {
+			value, ok := parseShellValue(tc.raw)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestCertConfig_Compare(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	ecKey := NewKey(writeTestFile(t, dir, "privkey.pem", pemEncodeKey(t, leaf.key)))
+	rsaFile := NewKey(writeTestFile(t, dir, "rsa.pem", pemEncodeKey(t, rsaKey)))
+	chain := NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(intermediate)))
+
+	testCases := []struct {
+		name       string
+		overrides  map[string]string
+		key        *Key
+		mismatches []string
+	}{
+		{"NoOverrides", map[string]string{}, ecKey, nil},
+		{"MatchingCurve", map[string]string{"KEY_ALGO": "prime256v1"}, ecKey, nil},
+		{"WrongCurve", map[string]string{"KEY_ALGO": "secp384r1"}, ecKey,
+			[]string{"KEY_ALGO=secp384r1 but the key is ecdsa P-256"}},
+		{"RSAExpected", map[string]string{"KEY_ALGO": "rsa"}, ecKey,
+			[]string{"KEY_ALGO=rsa but the key is ecdsa P-256"}},
+		{"ECExpected", map[string]string{"KEY_ALGO": "secp384r1"}, rsaFile,
+			[]string{"KEY_ALGO=secp384r1 but the key is rsa"}},
+		{"UnknownAlgo", map[string]string{"KEY_ALGO": "ed25519"}, ecKey,
+			[]string{"KEY_ALGO=ed25519 is not supported by dehydrated"}},
+		{"MatchingSize", map[string]string{"KEY_ALGO": "rsa", "KEYSIZE": "2048"}, rsaFile, nil},
+		{"WrongSize", map[string]string{"KEYSIZE": "4096"}, rsaFile,
+			[]string{"KEYSIZE=4096 but the key has 2048 bits"}},
+		{"SizeIgnoredForEC", map[string]string{"KEYSIZE": "4096"}, ecKey, nil},
+		{"InvalidSize", map[string]string{"KEYSIZE": "big"}, rsaFile, []string{"KEYSIZE=big is not a number"}},
+		{"MatchingChain", map[string]string{"PREFERRED_CHAIN": "Test Root"}, ecKey, nil},
+		{"WrongChain", map[string]string{"PREFERRED_CHAIN": "ISRG Root X1"}, ecKey,
+			[]string{"PREFERRED_CHAIN=ISRG Root X1 but the chain is issued by Test Root"}},
+		{"UnreadableKey", map[string]string{"KEY_ALGO": "rsa"}, NewKey("nonexistent"), nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &CertConfig{Overrides: tc.overrides}
+			cfg.Compare(tc.key, chain)
+			require.Equal(t, tc.mismatches, cfg.Mismatches)
+		})
+	}
+}
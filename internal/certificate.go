@@ -9,15 +9,27 @@ import (
 )
 
 // Certificate represents an X.509 certificate.
-// It holds metadata such as file path, subject, issuer, validity period, and potential errors during analysis.
+// It holds metadata such as file path, subject, issuer, serial number, validity period, subject alternative names,
+// fingerprints, extensions, and potential errors during analysis.
 type Certificate struct {
-	File      string    `json:"file"`                 // Path to the certificate file
-	Subject   string    `json:"subject,omitempty"`    // Certificate subject DN
-	Issuer    string    `json:"issuer,omitempty"`     // Certificate issuer DN
-	NotBefore time.Time `json:"not_before,omitempty"` // Start of validity period
-	NotAfter  time.Time `json:"not_after,omitempty"`  // End of validity period
-	DNSNames  []string  `json:"dns_names,omitempty"`  // List of DNS names associated with the certificate
-	Error     string    `json:"error,omitempty"`      // Error represents any error encountered during certificate analysis.
+	File           string            `json:"file"`                      // Path to the certificate file
+	Subject        string            `json:"subject,omitempty"`         // Certificate subject DN
+	Issuer         string            `json:"issuer,omitempty"`          // Certificate issuer DN
+	SerialNumber   string            `json:"serial_number,omitempty"`   // Serial number in colon separated hex
+	NotBefore      time.Time         `json:"not_before,omitempty"`      // Start of validity period
+	NotAfter       time.Time         `json:"not_after,omitempty"`       // End of validity period
+	Validity       *Validity         `json:"validity,omitempty"`        // Evaluation of the validity period, see Evaluate
+	DNSNames       []string          `json:"dns_names,omitempty"`       // List of DNS names associated with the certificate
+	IPAddresses    []string          `json:"ip_addresses,omitempty"`    // List of IP address SANs
+	EmailAddresses []string          `json:"email_addresses,omitempty"` // List of email address SANs
+	URIs           []string          `json:"uris,omitempty"`            // List of URI SANs
+	SANs           []*SubjectAltName `json:"sans,omitempty"`            // All subject alternative names with their type and wildcard flag
+	SANCount       int               `json:"san_count"`                 // Total number of subject alternative names
+	Fingerprints   *Fingerprints     `json:"fingerprints,omitempty"`    // Certificate and public key fingerprints
+	Extensions     *Extensions       `json:"extensions,omitempty"`      // Selected X.509 v3 extensions
+	Error          string            `json:"error,omitempty"`           // Error represents any error encountered during certificate analysis.
+
+	parsed *x509.Certificate // Parsed certificate
 }
 
 // NewCertificate creates a new Certificate instance from the provided file path and analyzes its metadata.
@@ -51,14 +63,52 @@ func (c *Certificate) analyze() error {
 		return err
 	}
 
+	c.populate(cert)
+
+	return nil
+}
+
+// populate copies the relevant fields of the parsed certificate into the Certificate metadata.
+func (c *Certificate) populate(cert *x509.Certificate) {
+	c.parsed = cert
 	c.Subject = cert.Subject.String()
 	c.DNSNames = []string{}
 	if cert.DNSNames != nil {
 		c.DNSNames = cert.DNSNames
 	}
+	for _, ip := range cert.IPAddresses {
+		c.IPAddresses = append(c.IPAddresses, ip.String())
+	}
+	c.EmailAddresses = cert.EmailAddresses
+	for _, uri := range cert.URIs {
+		c.URIs = append(c.URIs, uri.String())
+	}
+	c.SANs = newSubjectAltNames(cert)
+	c.SANCount = len(c.SANs)
 	c.Issuer = cert.Issuer.String()
+	c.SerialNumber = formatSerial(cert.SerialNumber)
+	c.Fingerprints = newFingerprints(cert)
+	c.Extensions = newExtensions(cert)
 	c.NotBefore = cert.NotBefore
 	c.NotAfter = cert.NotAfter
+}
 
-	return nil
+// X509 returns the parsed certificate, or nil if the certificate could not be analyzed.
+func (c *Certificate) X509() *x509.Certificate {
+	return c.parsed
+}
+
+// Evaluate computes the validity status of the certificate at the time now.
+// It is a no-op for certificates that could not be analyzed.
+func (c *Certificate) Evaluate(now time.Time, expiringWindow time.Duration) {
+	if c.NotAfter.IsZero() {
+		return
+	}
+	c.Validity = NewValidity(c.NotBefore, c.NotAfter, now, expiringWindow)
+}
+
+// Clone returns a copy of the certificate that can be evaluated independently of the original.
+func (c *Certificate) Clone() *Certificate {
+	clone := *c
+	return &clone
 }
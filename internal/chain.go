@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Chain represents an ordered list of X.509 certificates read from a single PEM file,
+// such as chain.pem or fullchain.pem.
+type Chain struct {
+	File         string        `json:"file"`            // Path to the chain file
+	Certificates []*ChainEntry `json:"certificates"`    // Certificates in the order they appear in the file
+	Error        string        `json:"error,omitempty"` // Error represents any error encountered during chain analysis.
+}
+
+// ChainEntry is a single certificate within a Chain together with its position in the file.
+type ChainEntry struct {
+	Position int `json:"position"` // Zero-based position of the certificate in the chain file
+	*Certificate
+}
+
+// NewChain creates a new Chain instance from the provided file path and analyzes every certificate it contains.
+func NewChain(file string) *Chain {
+	c := &Chain{
+		File:         file,
+		Certificates: []*ChainEntry{}, // Always initialize to empty slice
+	}
+	err := c.analyze()
+	if err != nil {
+		c.Error = err.Error()
+	}
+
+	return c
+}
+
+// analyze reads the chain file and parses each CERTIFICATE PEM block in order.
+// Parse errors of individual certificates are reported on the corresponding entry.
+func (c *Chain) analyze() error {
+	data, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", c.File, err)
+	}
+
+	for rest := data; len(rest) > 0; {
+		block, remaining := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		rest = remaining
+
+		// Skip anything that is not a certificate
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		entry := &ChainEntry{
+			Position: len(c.Certificates),
+			Certificate: &Certificate{
+				File:     c.File,
+				DNSNames: []string{},
+			},
+		}
+
+		cert, parseErr := x509.ParseCertificate(block.Bytes)
+		if parseErr != nil {
+			entry.Error = parseErr.Error()
+		} else {
+			entry.populate(cert)
+		}
+
+		c.Certificates = append(c.Certificates, entry)
+	}
+
+	if len(c.Certificates) == 0 {
+		return fmt.Errorf("failed to decode PEM block for %s", c.File)
+	}
+
+	return nil
+}
+
+// Evaluate computes the validity status of every certificate in the chain at the time now.
+func (c *Chain) Evaluate(now time.Time, expiringWindow time.Duration) {
+	for _, entry := range c.Certificates {
+		entry.Evaluate(now, expiringWindow)
+	}
+}
+
+// Clone returns a copy of the chain whose certificates can be evaluated independently of the original.
+func (c *Chain) Clone() *Chain {
+	clone := *c
+	clone.Certificates = make([]*ChainEntry, len(c.Certificates))
+	for i, entry := range c.Certificates {
+		clone.Certificates[i] = &ChainEntry{Position: entry.Position, Certificate: entry.Certificate.Clone()}
+	}
+	return &clone
+}
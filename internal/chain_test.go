@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChain_MultipleCertificates(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+
+	file := writeTestFile(t, t.TempDir(), "fullchain.pem", pemEncodeCerts(leaf, intermediate, root))
+
+	chain := NewChain(file)
+	require.NotNil(t, chain)
+	require.Empty(t, chain.Error)
+	require.Len(t, chain.Certificates, 3)
+
+	expected := []*testCert{leaf, intermediate, root}
+	for i, entry := range chain.Certificates {
+		require.Equal(t, i, entry.Position)
+		require.Empty(t, entry.Error)
+		require.Equal(t, expected[i].cert.Subject.String(), entry.Subject)
+		require.Equal(t, expected[i].cert.Issuer.String(), entry.Issuer)
+		require.True(t, expected[i].cert.NotAfter.Equal(entry.NotAfter))
+	}
+}
+
+func TestNewChain_SkipsNonCertificateBlocks(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+
+	content := append(pemEncodeKey(t, leaf.key), pemEncodeCerts(leaf)...)
+	file := writeTestFile(t, t.TempDir(), "chain.pem", content)
+
+	chain := NewChain(file)
+	require.Empty(t, chain.Error)
+	require.Len(t, chain.Certificates, 1)
+	require.Equal(t, "CN=example.com", chain.Certificates[0].Subject)
+}
+
+func TestNewChain_InvalidCertificateEntry(t *testing.T) {
+	content := []byte("-----BEGIN CERTIFICATE-----\naW52YWxpZA==\n-----END CERTIFICATE-----\n")
+	file := writeTestFile(t, t.TempDir(), "chain.pem", content)
+
+	chain := NewChain(file)
+	require.Empty(t, chain.Error)
+	require.Len(t, chain.Certificates, 1)
+	require.NotEmpty(t, chain.Certificates[0].Error)
+}
+
+func TestNewChain_NonExistentFile(t *testing.T) {
+	chain := NewChain("nonexistent.pem")
+	require.NotNil(t, chain)
+	require.Contains(t, chain.Error, "failed to read")
+	require.NotNil(t, chain.Certificates)
+}
+
+func TestNewChain_InvalidContent(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "chain.pem", []byte("invalid content"))
+
+	chain := NewChain(file)
+	require.Contains(t, chain.Error, "failed to decode PEM block")
+}
+
+func TestChain_Clone(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	chain := NewChain(writeTestFile(t, t.TempDir(), "chain.pem", pemEncodeCerts(intermediate, root)))
+
+	clone := chain.Clone()
+	clone.Evaluate(time.Now(), DefaultExpiringWindow)
+	require.Len(t, clone.Certificates, 2)
+	require.NotNil(t, clone.Certificates[1].Validity)
+	require.Equal(t, 1, clone.Certificates[1].Position)
+	require.Nil(t, chain.Certificates[1].Validity)
+	require.Same(t, chain.Certificates[0].X509(), clone.Certificates[0].X509())
+}
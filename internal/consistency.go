@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+)
+
+// Consistency describes whether the files of a domain directory belong together.
+// A check is omitted when one of the files it depends on could not be analyzed; the reason is listed in Errors.
+type Consistency struct {
+	KeyMatchesCertificate        *bool    `json:"key_matches_certificate,omitempty"`          // privkey.pem is the private key of cert.pem
+	FullchainLeafMatchesCert     *bool    `json:"fullchain_leaf_matches_cert,omitempty"`      // First certificate of fullchain.pem is cert.pem
+	FullchainMatchesCertAndChain *bool    `json:"fullchain_matches_cert_and_chain,omitempty"` // fullchain.pem is cert.pem plus chain.pem
+	Errors                       []string `json:"errors,omitempty"`                           // Reasons why checks could not be performed
+}
+
+// NewConsistency compares the analyzed key, certificate, chain and fullchain of a domain with each other.
+func NewConsistency(key *Key, cert *Certificate, chain, fullchain *Chain) *Consistency {
+	c := &Consistency{}
+
+	leaf := cert.X509()
+	if leaf == nil {
+		c.Errors = append(c.Errors, "certificate could not be analyzed")
+	}
+	if key.PublicKey() == nil {
+		c.Errors = append(c.Errors, "private key could not be analyzed")
+	}
+	fullchainDER, fullchainOK := chainDER(fullchain)
+	if !fullchainOK {
+		c.Errors = append(c.Errors, "fullchain could not be analyzed")
+	}
+	chainCerts, chainOK := chainDER(chain)
+	if !chainOK {
+		c.Errors = append(c.Errors, "chain could not be analyzed")
+	}
+
+	if leaf == nil {
+		return c
+	}
+
+	if key.PublicKey() != nil {
+		c.KeyMatchesCertificate = boolPtr(publicKeysEqual(key.PublicKey(), leaf.PublicKey))
+	}
+
+	if !fullchainOK {
+		return c
+	}
+
+	c.FullchainLeafMatchesCert = boolPtr(len(fullchainDER) > 0 && bytes.Equal(fullchainDER[0], leaf.Raw))
+
+	if chainOK {
+		expected := append([][]byte{leaf.Raw}, chainCerts...)
+		c.FullchainMatchesCertAndChain = boolPtr(derListsEqual(expected, fullchainDER))
+	}
+
+	return c
+}
+
+// chainDER returns the DER encoding of every certificate in the chain.
+// It reports false if the chain or any of its certificates could not be analyzed.
+func chainDER(chain *Chain) ([][]byte, bool) {
+	if chain.Error != "" {
+		return nil, false
+	}
+	der := make([][]byte, 0, len(chain.Certificates))
+	for _, entry := range chain.Certificates {
+		if entry.X509() == nil {
+			return nil, false
+		}
+		der = append(der, entry.X509().Raw)
+	}
+	return der, true
+}
+
+// derListsEqual reports whether both lists contain the same certificates in the same order.
+func derListsEqual(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicKeysEqual reports whether both public keys are of the same type and value.
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+// boolPtr returns a pointer to b.
+func boolPtr(b bool) *bool {
+	return &b
+}
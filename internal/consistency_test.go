@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeDomainFiles writes the dehydrated file set of a domain and returns the analyzed objects.
+func writeDomainFiles(t *testing.T, keyPEM, certPEM, chainPEM, fullchainPEM []byte) (*Key, *Certificate, *Chain, *Chain) {
+	t.Helper()
+	dir := t.TempDir()
+	return NewKey(writeTestFile(t, dir, "privkey.pem", keyPEM)),
+		NewCertificate(writeTestFile(t, dir, "cert.pem", certPEM)),
+		NewChain(writeTestFile(t, dir, "chain.pem", chainPEM)),
+		NewChain(writeTestFile(t, dir, "fullchain.pem", fullchainPEM))
+}
+
+func TestNewConsistency_Consistent(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+
+	c := NewConsistency(writeDomainFiles(t,
+		pemEncodeKey(t, leaf.key),
+		pemEncodeCerts(leaf),
+		pemEncodeCerts(intermediate),
+		pemEncodeCerts(leaf, intermediate),
+	))
+
+	require.Empty(t, c.Errors)
+	require.True(t, *c.KeyMatchesCertificate)
+	require.True(t, *c.FullchainLeafMatchesCert)
+	require.True(t, *c.FullchainMatchesCertAndChain)
+}
+
+func TestNewConsistency_Mismatches(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+	oldLeaf := newTestLeaf(t, intermediate, "example.com")
+
+	c := NewConsistency(writeDomainFiles(t,
+		pemEncodeKey(t, oldLeaf.key),
+		pemEncodeCerts(leaf),
+		pemEncodeCerts(intermediate),
+		pemEncodeCerts(oldLeaf, intermediate, root),
+	))
+
+	require.Empty(t, c.Errors)
+	require.False(t, *c.KeyMatchesCertificate)
+	require.False(t, *c.FullchainLeafMatchesCert)
+	require.False(t, *c.FullchainMatchesCertAndChain)
+}
+
+func TestNewConsistency_ExtraIntermediate(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+
+	c := NewConsistency(writeDomainFiles(t,
+		pemEncodeKey(t, leaf.key),
+		pemEncodeCerts(leaf),
+		pemEncodeCerts(intermediate),
+		pemEncodeCerts(leaf, intermediate, root),
+	))
+
+	require.True(t, *c.FullchainLeafMatchesCert)
+	require.False(t, *c.FullchainMatchesCertAndChain)
+}
+
+func TestNewConsistency_MissingFiles(t *testing.T) {
+	c := NewConsistency(NewKey("nonexistent.key"), NewCertificate("nonexistent.crt"), NewChain("nonexistent.pem"), NewChain("nonexistent.pem"))
+
+	require.Nil(t, c.KeyMatchesCertificate)
+	require.Nil(t, c.FullchainLeafMatchesCert)
+	require.Nil(t, c.FullchainMatchesCertAndChain)
+	require.Len(t, c.Errors, 4)
+}
+
+func TestNewConsistency_MissingKey(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+	dir := t.TempDir()
+
+	c := NewConsistency(
+		NewKey("nonexistent.key"),
+		NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf))),
+		NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(root))),
+		NewChain(writeTestFile(t, dir, "fullchain.pem", pemEncodeCerts(leaf, root))),
+	)
+
+	require.Nil(t, c.KeyMatchesCertificate)
+	require.True(t, *c.FullchainLeafMatchesCert)
+	require.True(t, *c.FullchainMatchesCertAndChain)
+	require.Equal(t, []string{"private key could not be analyzed"}, c.Errors)
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"strings"
+)
+
+// Coverage compares the names of a dehydrated domain entry with the subject alternative names of its certificate.
+type Coverage struct {
+	Complete bool     `json:"complete"`          // Whether every expected name is covered by the certificate
+	Covered  []string `json:"covered,omitempty"` // Expected names covered by a SAN of the certificate
+	Missing  []string `json:"missing,omitempty"` // Expected names not covered by any SAN of the certificate
+	Extra    []string `json:"extra,omitempty"`   // SANs of the certificate not needed for any expected name
+	Error    string   `json:"error,omitempty"`   // Error represents any error that prevented the comparison.
+}
+
+// NewCoverage checks which of the expected names are covered by the DNS and IP SANs of the certificate.
+// Names are compared case-insensitively and a wildcard SAN covers exactly one additional leftmost label.
+func NewCoverage(names []string, cert *Certificate) *Coverage {
+	c := &Coverage{}
+	if cert.X509() == nil {
+		c.Error = "certificate could not be analyzed"
+		return c
+	}
+
+	var sans []string
+	for _, san := range cert.SANs {
+		if san.Type == SANTypeDNS || san.Type == SANTypeIP {
+			sans = append(sans, strings.ToLower(san.Value))
+		}
+	}
+
+	used := make(map[string]bool, len(sans))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		covered := false
+		for _, san := range sans {
+			if nameMatches(san, name) {
+				used[san] = true
+				covered = true
+			}
+		}
+
+		if covered {
+			c.Covered = append(c.Covered, name)
+		} else {
+			c.Missing = append(c.Missing, name)
+		}
+	}
+
+	for _, san := range sans {
+		if !used[san] {
+			c.Extra = append(c.Extra, san)
+		}
+	}
+
+	c.Complete = len(c.Missing) == 0
+
+	return c
+}
+
+// nameMatches reports whether the SAN covers the name. Both are expected in lower case.
+// A wildcard name in the domain entry is only covered by the identical wildcard SAN.
+func nameMatches(san, name string) bool {
+	if san == name {
+		return true
+	}
+	if !isWildcard(san) || isWildcard(name) {
+		return false
+	}
+
+	label, parent, found := strings.Cut(name, ".")
+	return found && label != "" && parent == san[len("*."):]
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCoverage(t *testing.T) {
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "example.com"},
+		DNSNames:    []string{"example.com", "*.example.com", "old.example.org"},
+		IPAddresses: []net.IP{net.ParseIP("192.0.2.1")},
+	}, nil, newTestCA(t, "Test Root", nil))
+	cert := NewCertificate(writeTestFile(t, t.TempDir(), "cert.pem", pemEncodeCerts(leaf)))
+
+	c := NewCoverage([]string{"Example.com", "www.example.com", "a.b.example.com", "example.net", "www.example.com", "192.0.2.1"}, cert)
+	require.Empty(t, c.Error)
+	require.False(t, c.Complete)
+	require.Equal(t, []string{"example.com", "www.example.com", "192.0.2.1"}, c.Covered)
+	require.Equal(t, []string{"a.b.example.com", "example.net"}, c.Missing)
+	require.Equal(t, []string{"old.example.org"}, c.Extra)
+}
+
+func TestNewCoverage_Complete(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com", "www.example.com")
+	cert := NewCertificate(writeTestFile(t, t.TempDir(), "cert.pem", pemEncodeCerts(leaf)))
+
+	c := NewCoverage([]string{"example.com", "www.example.com"}, cert)
+	require.True(t, c.Complete)
+	require.Empty(t, c.Missing)
+	require.Empty(t, c.Extra)
+}
+
+func TestNewCoverage_MissingCertificate(t *testing.T) {
+	c := NewCoverage([]string{"example.com"}, NewCertificate("nonexistent.crt"))
+	require.False(t, c.Complete)
+	require.Equal(t, "certificate could not be analyzed", c.Error)
+}
+
+func TestNameMatches(t *testing.T) {
+	testCases := []struct {
+		san      string
+		name     string
+		expected bool
+	}{
+		{"example.com", "example.com", true},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.example.com", "*.example.com", true},
+		{"www.example.com", "*.example.com", false},
+		{"*.example.com", "*.www.example.com", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.san+"/"+tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, nameMatches(tc.san, tc.name))
+		})
+	}
+}
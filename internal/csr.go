@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CSR represents a PKCS#10 certificate signing request, such as the cert.csr dehydrated leaves in the domain directory.
+type CSR struct {
+	File                   string            `json:"file"`                               // Path to the CSR file
+	Subject                string            `json:"subject,omitempty"`                  // Requested subject DN
+	DNSNames               []string          `json:"dns_names,omitempty"`                // Requested DNS names
+	IPAddresses            []string          `json:"ip_addresses,omitempty"`             // Requested IP addresses
+	EmailAddresses         []string          `json:"email_addresses,omitempty"`          // Requested email addresses
+	URIs                   []string          `json:"uris,omitempty"`                     // Requested URIs
+	SANs                   []*SubjectAltName `json:"sans,omitempty"`                     // All requested subject alternative names
+	PublicKeyType          string            `json:"public_key_type,omitempty"`          // Public key algorithm: rsa, ecdsa or ed25519
+	PublicKeySize          int               `json:"public_key_size,omitempty"`          // Public key size in bits
+	PublicKeyCurve         string            `json:"public_key_curve,omitempty"`         // Named curve of elliptic curve keys
+	SignatureAlgorithm     string            `json:"signature_algorithm,omitempty"`      // Algorithm of the CSR signature
+	SignatureValid         bool              `json:"signature_valid"`                    // Whether the CSR is signed by its own public key
+	MatchesKey             *bool             `json:"matches_key,omitempty"`              // Whether the key belongs to privkey.pem, see Compare
+	MatchesCertificateSANs *bool             `json:"matches_certificate_sans,omitempty"` // Whether the SANs match cert.pem, see Compare
+	Error                  string            `json:"error,omitempty"`                    // Error represents any CSR analysis error.
+
+	parsed *x509.CertificateRequest // Parsed certificate request
+}
+
+// NewCSR creates a new CSR instance from the provided file path and analyzes its metadata.
+func NewCSR(file string) *CSR {
+	c := &CSR{
+		File: file,
+	}
+	err := c.analyze()
+	if err != nil {
+		c.Error = err.Error()
+	}
+
+	return c
+}
+
+// Clone returns a copy of the CSR that can be compared independently of the original.
+func (c *CSR) Clone() *CSR {
+	clone := *c
+	return &clone
+}
+
+// analyze reads and parses the CSR file and checks its signature.
+func (c *CSR) analyze() error {
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", c.File, err)
+	}
+
+	// Decode the PEM block
+	bp, _ := pem.Decode(b)
+	if bp == nil {
+		return fmt.Errorf("failed to decode PEM block for %s", c.File)
+	}
+	csr, err := x509.ParseCertificateRequest(bp.Bytes)
+	if err != nil {
+		return err
+	}
+	c.parsed = csr
+
+	c.Subject = csr.Subject.String()
+	c.DNSNames = csr.DNSNames
+	for _, ip := range csr.IPAddresses {
+		c.IPAddresses = append(c.IPAddresses, ip.String())
+	}
+	c.EmailAddresses = csr.EmailAddresses
+	for _, uri := range csr.URIs {
+		c.URIs = append(c.URIs, uri.String())
+	}
+	c.SANs = newSubjectAltNames(&x509.Certificate{
+		DNSNames:       csr.DNSNames,
+		IPAddresses:    csr.IPAddresses,
+		EmailAddresses: csr.EmailAddresses,
+		URIs:           csr.URIs,
+	})
+	c.PublicKeyType, c.PublicKeySize, c.PublicKeyCurve = describePublicKey(csr.PublicKey)
+	c.SignatureAlgorithm = csr.SignatureAlgorithm.String()
+
+	if err = csr.CheckSignature(); err != nil {
+		return fmt.Errorf("invalid CSR signature: %w", err)
+	}
+	c.SignatureValid = true
+
+	return nil
+}
+
+// Compare checks whether the CSR belongs to the private key and whether it requested the SANs of the certificate.
+// Checks whose inputs could not be analyzed are left unset.
+func (c *CSR) Compare(key *Key, cert *Certificate) {
+	if c.parsed == nil {
+		return
+	}
+	if key.PublicKey() != nil {
+		c.MatchesKey = boolPtr(publicKeysEqual(key.PublicKey(), c.parsed.PublicKey))
+	}
+	if cert.X509() != nil {
+		c.MatchesCertificateSANs = boolPtr(slices.Equal(sanSet(c.SANs), sanSet(cert.SANs)))
+	}
+}
+
+// sanSet returns the sorted, lower cased values of the subject alternative names with their type.
+func sanSet(sans []*SubjectAltName) []string {
+	set := make([]string, 0, len(sans))
+	for _, san := range sans {
+		set = append(set, san.Type+":"+strings.ToLower(san.Value))
+	}
+	slices.Sort(set)
+	return slices.Compact(set)
+}
+
+// describePublicKey returns the algorithm, size in bits and named curve of a public key.
+func describePublicKey(pub crypto.PublicKey) (keyType string, size int, curve string) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return KeyTypeRSA, k.N.BitLen(), ""
+	case *ecdsa.PublicKey:
+		return KeyTypeECDSA, k.Curve.Params().BitSize, k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return KeyTypeEd25519, ed25519Bits, "Ed25519"
+	default:
+		return "", 0, ""
+	}
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pemEncodeCSR creates a CSR for the DNS and IP names signed by the key and returns its PEM encoding.
+func pemEncodeCSR(t *testing.T, key *testCert, dnsNames []string, ips ...net.IP) []byte {
+	t.Helper()
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+	}, key.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestNewCSR(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com", "www.example.com")
+	file := writeTestFile(t, t.TempDir(), "cert.csr",
+		pemEncodeCSR(t, leaf, []string{"example.com", "www.example.com"}, net.ParseIP("192.0.2.1")))
+
+	csr := NewCSR(file)
+	require.Empty(t, csr.Error)
+	require.Equal(t, "CN=example.com", csr.Subject)
+	require.Equal(t, []string{"example.com", "www.example.com"}, csr.DNSNames)
+	require.Equal(t, []string{"192.0.2.1"}, csr.IPAddresses)
+	require.Len(t, csr.SANs, 3)
+	require.Equal(t, KeyTypeECDSA, csr.PublicKeyType)
+	require.Equal(t, 256, csr.PublicKeySize)
+	require.Equal(t, "P-256", csr.PublicKeyCurve)
+	require.Equal(t, "ECDSA-SHA256", csr.SignatureAlgorithm)
+	require.True(t, csr.SignatureValid)
+}
+
+func TestCSR_Compare(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com", "www.example.com")
+	other := newTestLeaf(t, root, "example.com")
+	dir := t.TempDir()
+
+	key := NewKey(writeTestFile(t, dir, "privkey.pem", pemEncodeKey(t, leaf.key)))
+	cert := NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf)))
+
+	csr := NewCSR(writeTestFile(t, dir, "cert.csr", pemEncodeCSR(t, leaf, []string{"WWW.example.com", "example.com"})))
+	csr.Compare(key, cert)
+	require.True(t, *csr.MatchesKey)
+	require.True(t, *csr.MatchesCertificateSANs)
+
+	csr = NewCSR(writeTestFile(t, dir, "other.csr", pemEncodeCSR(t, other, []string{"example.com"})))
+	csr.Compare(key, cert)
+	require.False(t, *csr.MatchesKey)
+	require.False(t, *csr.MatchesCertificateSANs)
+
+	csr.MatchesKey, csr.MatchesCertificateSANs = nil, nil
+	csr.Compare(NewKey("nonexistent.key"), NewCertificate("nonexistent.crt"))
+	require.Nil(t, csr.MatchesKey)
+	require.Nil(t, csr.MatchesCertificateSANs)
+}
+
+func TestNewCSR_InvalidSignature(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com")
+	block, _ := pem.Decode(pemEncodeCSR(t, leaf, []string{"example.com"}))
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	file := writeTestFile(t, t.TempDir(), "cert.csr", pem.EncodeToMemory(block))
+
+	csr := NewCSR(file)
+	require.False(t, csr.SignatureValid)
+	require.Contains(t, csr.Error, "invalid CSR signature")
+	require.Equal(t, "CN=example.com", csr.Subject)
+}
+
+func TestNewCSR_InvalidFile(t *testing.T) {
+	csr := NewCSR("nonexistent.csr")
+	require.Contains(t, csr.Error, "failed to read")
+
+	csr = NewCSR(writeTestFile(t, t.TempDir(), "cert.csr", []byte("invalid content")))
+	require.Contains(t, csr.Error, "failed to decode PEM block")
+
+	csr.Compare(NewKey("nonexistent.key"), NewCertificate("nonexistent.crt"))
+	require.Nil(t, csr.MatchesKey)
+}
@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"  //nolint:gosec // 3DES is only used to decrypt legacy encrypted keys
+	"crypto/sha1" //nolint:gosec // SHA-1 is the default PRF of PBKDF2
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Key encryption formats as reported in KeyEncryption.Format.
+const (
+	KeyEncryptionFormatPKCS8  = "pkcs8"
+	KeyEncryptionFormatLegacy = "legacy"
+)
+
+// Upper bounds of the key derivation parameters read from the key file. A corrupt or malicious key exceeding them
+// is rejected instead of spending minutes or gigabytes of memory on deriving its key.
+const (
+	maxPBKDF2Iterations = 10_000_000
+	maxScryptN          = 1 << 20
+	maxScryptMemory     = 1 << 30 // 128 * r * N bytes
+	maxScryptP          = 16
+)
+
+// errNoPassphrase is returned for encrypted keys when no passphrase is configured.
+var errNoPassphrase = errors.New("key is encrypted and no passphrase is configured")
+
+// errIncorrectPassphrase is returned when an encrypted key cannot be decrypted with the configured passphrase.
+var errIncorrectPassphrase = errors.New("failed to decrypt key, incorrect passphrase or corrupt key")
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+
+	oidHMACWithSHA1 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+)
+
+// pbkdf2PRFs maps the PBKDF2 pseudo random function OIDs to their names and hash functions.
+var pbkdf2PRFs = map[string]struct {
+	name string
+	hash func() hash.Hash
+}{
+	oidHMACWithSHA1.String(): {"hmacWithSHA1", sha1.New},
+	"1.2.840.113549.2.8":     {"hmacWithSHA224", sha256.New224},
+	"1.2.840.113549.2.9":     {"hmacWithSHA256", sha256.New},
+	"1.2.840.113549.2.10":    {"hmacWithSHA384", sha512.New384},
+	"1.2.840.113549.2.11":    {"hmacWithSHA512", sha512.New},
+	"1.2.840.113549.2.12":    {"hmacWithSHA512-224", sha512.New512_224},
+	"1.2.840.113549.2.13":    {"hmacWithSHA512-256", sha512.New512_256},
+}
+
+// pbes2Ciphers maps the PBES2 encryption scheme OIDs to their names, key sizes and block ciphers.
+var pbes2Ciphers = map[string]struct {
+	name     string
+	keySize  int
+	newBlock func(key []byte) (cipher.Block, error)
+}{
+	"2.16.840.1.101.3.4.1.2":  {"aes-128-cbc", 16, aes.NewCipher},
+	"2.16.840.1.101.3.4.1.22": {"aes-192-cbc", 24, aes.NewCipher},
+	"2.16.840.1.101.3.4.1.42": {"aes-256-cbc", 32, aes.NewCipher},
+	"1.2.840.113549.3.7":      {"des-ede3-cbc", 24, des.NewTripleDESCipher},
+}
+
+// pbes1Schemes maps PKCS#5 v1 and PKCS#12 password based encryption OIDs to their names.
+// These schemes are recognized but not decrypted.
+var pbes1Schemes = map[string]string{
+	"1.2.840.113549.1.5.3":    "pbeWithMD5AndDES-CBC",
+	"1.2.840.113549.1.5.10":   "pbeWithSHA1AndDES-CBC",
+	"1.2.840.113549.1.12.1.1": "pbeWithSHA1And128BitRC4",
+	"1.2.840.113549.1.12.1.2": "pbeWithSHA1And40BitRC4",
+	"1.2.840.113549.1.12.1.3": "pbeWithSHA1And3-KeyTripleDES-CBC",
+	"1.2.840.113549.1.12.1.4": "pbeWithSHA1And2-KeyTripleDES-CBC",
+	"1.2.840.113549.1.12.1.5": "pbeWithSHA1And128BitRC2-CBC",
+	"1.2.840.113549.1.12.1.6": "pbeWithSHA1And40BitRC2-CBC",
+}
+
+// KeyEncryption describes how an encrypted private key is protected.
+type KeyEncryption struct {
+	Format     string `json:"format"`               // pkcs8 for ENCRYPTED PRIVATE KEY blocks, legacy for Proc-Type headers
+	Scheme     string `json:"scheme,omitempty"`     // Encryption scheme of PKCS#8 keys, e.g. PBES2
+	KDF        string `json:"kdf,omitempty"`        // Key derivation function of PBES2 keys, PBKDF2 or scrypt
+	PRF        string `json:"prf,omitempty"`        // Pseudo random function of PBKDF2, e.g. hmacWithSHA256
+	Iterations int    `json:"iterations,omitempty"` // Iteration count of PBKDF2
+	ScryptN    int    `json:"scrypt_n,omitempty"`   // CPU/memory cost parameter of scrypt
+	ScryptR    int    `json:"scrypt_r,omitempty"`   // Block size parameter of scrypt
+	ScryptP    int    `json:"scrypt_p,omitempty"`   // Parallelization parameter of scrypt
+	Cipher     string `json:"cipher,omitempty"`     // Cipher, e.g. aes-256-cbc
+	Decrypted  bool   `json:"decrypted"`            // Whether the key could be decrypted with the configured passphrase
+}
+
+// isEncryptedBlock reports whether the PEM block holds an encrypted private key.
+func isEncryptedBlock(block *pem.Block) bool {
+	//nolint:staticcheck // legacy PEM encryption has to be detected to describe such keys
+	return block.Type == "ENCRYPTED PRIVATE KEY" || x509.IsEncryptedPEMBlock(block)
+}
+
+// decryptBlock describes the encryption of the PEM block and, if a passphrase is given, returns the decrypted DER.
+func decryptBlock(block *pem.Block, passphrase []byte) (*KeyEncryption, []byte, error) {
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return decryptPKCS8(block.Bytes, passphrase)
+	}
+
+	enc := &KeyEncryption{Format: KeyEncryptionFormatLegacy}
+	if dekInfo, _, found := strings.Cut(block.Headers["DEK-Info"], ","); found {
+		enc.Cipher = strings.ToLower(dekInfo)
+	}
+	if passphrase == nil {
+		return enc, nil, errNoPassphrase
+	}
+
+	der, err := x509.DecryptPEMBlock(block, passphrase) //nolint:staticcheck // see isEncryptedBlock
+	if err != nil {
+		return enc, nil, errIncorrectPassphrase
+	}
+	enc.Decrypted = true
+
+	return enc, der, nil
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+// decryptPKCS8 describes the encryption of a PKCS#8 EncryptedPrivateKeyInfo and, if a passphrase is given,
+// returns the decrypted PrivateKeyInfo. Only PBES2 with PBKDF2 or scrypt and CBC mode ciphers can be decrypted.
+func decryptPKCS8(der, passphrase []byte) (*KeyEncryption, []byte, error) {
+	enc := &KeyEncryption{Format: KeyEncryptionFormatPKCS8}
+
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return enc, nil, fmt.Errorf("failed to parse encrypted private key: %w", err)
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		enc.Scheme = info.Algorithm.Algorithm.String()
+		if name, ok := pbes1Schemes[enc.Scheme]; ok {
+			enc.Scheme = name
+		}
+		return enc, nil, fmt.Errorf("unsupported key encryption scheme %s", enc.Scheme)
+	}
+	enc.Scheme = "PBES2"
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return enc, nil, fmt.Errorf("failed to parse PBES2 parameters: %w", err)
+	}
+
+	scheme, ok := pbes2Ciphers[params.EncryptionScheme.Algorithm.String()]
+	if !ok {
+		enc.Cipher = params.EncryptionScheme.Algorithm.String()
+		return enc, nil, fmt.Errorf("unsupported key encryption cipher %s", enc.Cipher)
+	}
+	enc.Cipher = scheme.name
+
+	deriveKey, err := newKeyDerivation(enc, params.KeyDerivationFunc, scheme.keySize)
+	if err != nil {
+		return enc, nil, err
+	}
+
+	if passphrase == nil {
+		return enc, nil, errNoPassphrase
+	}
+
+	var iv []byte
+	if _, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return enc, nil, fmt.Errorf("failed to parse cipher parameters: %w", err)
+	}
+
+	key, err := deriveKey(passphrase)
+	if err != nil {
+		return enc, nil, err
+	}
+	block, err := scheme.newBlock(key)
+	if err != nil {
+		return enc, nil, err
+	}
+	plain, err := decryptCBC(block, iv, info.EncryptedData)
+	if err != nil {
+		return enc, nil, err
+	}
+	enc.Decrypted = true
+
+	return enc, plain, nil
+}
+
+// newKeyDerivation describes the PBES2 key derivation function in enc and returns a function deriving keySize bytes.
+func newKeyDerivation(enc *KeyEncryption, kdf pkix.AlgorithmIdentifier, keySize int) (func([]byte) ([]byte, error), error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		enc.KDF = "PBKDF2"
+		var params pbkdf2Params
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("failed to parse PBKDF2 parameters: %w", err)
+		}
+		prfOID := params.PRF.Algorithm
+		if len(prfOID) == 0 {
+			prfOID = oidHMACWithSHA1
+		}
+		prf, ok := pbkdf2PRFs[prfOID.String()]
+		if !ok {
+			enc.PRF = prfOID.String()
+			return nil, fmt.Errorf("unsupported PBKDF2 pseudo random function %s", enc.PRF)
+		}
+		enc.PRF = prf.name
+		enc.Iterations = params.IterationCount
+		if params.IterationCount > maxPBKDF2Iterations {
+			return nil, fmt.Errorf("PBKDF2 iteration count %d exceeds the limit of %d", params.IterationCount, maxPBKDF2Iterations)
+		}
+
+		return func(passphrase []byte) ([]byte, error) {
+			return pbkdf2.Key(passphrase, params.Salt, params.IterationCount, keySize, prf.hash), nil
+		}, nil
+	case kdf.Algorithm.Equal(oidScrypt):
+		enc.KDF = "scrypt"
+		var params scryptParams
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("failed to parse scrypt parameters: %w", err)
+		}
+		enc.ScryptN = params.CostParameter
+		enc.ScryptR = params.BlockSize
+		enc.ScryptP = params.ParallelizationParameter
+		if err := checkScryptParams(params); err != nil {
+			return nil, err
+		}
+
+		return func(passphrase []byte) ([]byte, error) {
+			return scrypt.Key(passphrase, params.Salt, params.CostParameter, params.BlockSize, params.ParallelizationParameter, keySize)
+		}, nil
+	default:
+		enc.KDF = kdf.Algorithm.String()
+		return nil, fmt.Errorf("unsupported key derivation function %s", enc.KDF)
+	}
+}
+
+// checkScryptParams rejects scrypt parameters whose key derivation would take too much time or memory.
+func checkScryptParams(params scryptParams) error {
+	n, r, p := params.CostParameter, params.BlockSize, params.ParallelizationParameter
+	switch {
+	case n > maxScryptN:
+		return fmt.Errorf("scrypt cost parameter %d exceeds the limit of %d", n, maxScryptN)
+	case int64(n)*int64(r)*128 > maxScryptMemory:
+		return fmt.Errorf("scrypt parameters N=%d and r=%d exceed the memory limit of %d bytes", n, r, maxScryptMemory)
+	case p > maxScryptP:
+		return fmt.Errorf("scrypt parallelization parameter %d exceeds the limit of %d", p, maxScryptP)
+	default:
+		return nil
+	}
+}
+
+// decryptCBC decrypts the CBC mode ciphertext and removes the PKCS#7 padding.
+func decryptCBC(block cipher.Block, iv, ciphertext []byte) ([]byte, error) {
+	bs := block.BlockSize()
+	if len(iv) != bs || len(ciphertext) == 0 || len(ciphertext)%bs != 0 {
+		return nil, errIncorrectPassphrase
+	}
+
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ciphertext)
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > bs {
+		return nil, errIncorrectPassphrase
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errIncorrectPassphrase
+		}
+	}
+
+	return plain[:len(plain)-pad], nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"crypto/x509"
+)
+
+// keyUsageNames maps the x509.KeyUsage bits to their RFC 5280 names.
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// extKeyUsageNames maps the x509.ExtKeyUsage values to their RFC 5280 names.
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "anyExtendedKeyUsage",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "nsSGC",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "msCodeCom",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernelCodeSigning",
+}
+
+// Extensions holds the commonly inspected X.509 v3 extensions of a certificate.
+type Extensions struct {
+	KeyUsage               []string `json:"key_usage,omitempty"`                // Key usage names, e.g. digitalSignature
+	ExtKeyUsage            []string `json:"ext_key_usage,omitempty"`            // Extended key usage names or OIDs, e.g. serverAuth
+	BasicConstraintsValid  bool     `json:"basic_constraints_valid"`            // Whether the basic constraints extension is present
+	IsCA                   bool     `json:"is_ca"`                              // Whether the certificate is a CA certificate
+	MaxPathLen             *int     `json:"max_path_len,omitempty"`             // Path length constraint, if any
+	SubjectKeyID           string   `json:"subject_key_id,omitempty"`           // Subject key identifier in colon separated hex
+	AuthorityKeyID         string   `json:"authority_key_id,omitempty"`         // Authority key identifier in colon separated hex
+	OCSPServers            []string `json:"ocsp_servers,omitempty"`             // OCSP responder URLs from the AIA extension
+	IssuingCertificateURLs []string `json:"issuing_certificate_urls,omitempty"` // CA issuer URLs from the AIA extension
+	CRLDistributionPoints  []string `json:"crl_distribution_points,omitempty"`  // CRL distribution point URLs
+	PolicyOIDs             []string `json:"policy_oids,omitempty"`              // Certificate policy OIDs
+}
+
+// newExtensions extracts the extension metadata from the given certificate.
+func newExtensions(cert *x509.Certificate) *Extensions {
+	e := &Extensions{
+		BasicConstraintsValid:  cert.BasicConstraintsValid,
+		IsCA:                   cert.IsCA,
+		SubjectKeyID:           colonHex(cert.SubjectKeyId),
+		AuthorityKeyID:         colonHex(cert.AuthorityKeyId),
+		OCSPServers:            cert.OCSPServer,
+		IssuingCertificateURLs: cert.IssuingCertificateURL,
+		CRLDistributionPoints:  cert.CRLDistributionPoints,
+	}
+
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			e.KeyUsage = append(e.KeyUsage, ku.name)
+		}
+	}
+
+	for _, eku := range cert.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[eku]; ok {
+			e.ExtKeyUsage = append(e.ExtKeyUsage, name)
+		}
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		e.ExtKeyUsage = append(e.ExtKeyUsage, oid.String())
+	}
+
+	// MaxPathLen is only meaningful for CA certificates with basic constraints;
+	// a value of 0 is only a constraint when MaxPathLenZero is set.
+	if cert.BasicConstraintsValid && cert.IsCA && (cert.MaxPathLen > 0 || cert.MaxPathLenZero) {
+		pathLen := cert.MaxPathLen
+		e.MaxPathLen = &pathLen
+	}
+
+	for _, oid := range cert.PolicyIdentifiers {
+		e.PolicyOIDs = append(e.PolicyOIDs, oid.String())
+	}
+
+	return e
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExtensions_Leaf(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		UnknownExtKeyUsage:    []asn1.ObjectIdentifier{{1, 2, 3, 4}},
+		BasicConstraintsValid: true,
+		SubjectKeyId:          []byte{0x01, 0x02, 0x03},
+		OCSPServer:            []string{"http://ocsp.example.com"},
+		IssuingCertificateURL: []string{"http://ca.example.com/ca.crt"},
+		CRLDistributionPoints: []string{"http://crl.example.com/ca.crl"},
+		PolicyIdentifiers:     []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}},
+	}, nil, root)
+
+	e := newExtensions(leaf.cert)
+	require.Equal(t, []string{"digitalSignature", "keyEncipherment"}, e.KeyUsage)
+	require.Equal(t, []string{"serverAuth", "1.2.3.4"}, e.ExtKeyUsage)
+	require.True(t, e.BasicConstraintsValid)
+	require.False(t, e.IsCA)
+	require.Nil(t, e.MaxPathLen)
+	require.Equal(t, "01:02:03", e.SubjectKeyID)
+	require.Equal(t, colonHex(root.cert.SubjectKeyId), e.AuthorityKeyID)
+	require.Equal(t, []string{"http://ocsp.example.com"}, e.OCSPServers)
+	require.Equal(t, []string{"http://ca.example.com/ca.crt"}, e.IssuingCertificateURLs)
+	require.Equal(t, []string{"http://crl.example.com/ca.crl"}, e.CRLDistributionPoints)
+	require.Equal(t, []string{"2.23.140.1.2.1"}, e.PolicyOIDs)
+}
+
+func TestNewExtensions_CAPathLen(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxPathLen int
+		zero       bool
+		expected   *int
+	}{
+		{"Unconstrained", -1, false, nil},
+		{"Zero", 0, true, new(int)},
+		{"One", 1, false, func() *int { v := 1; return &v }()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ca := newTestCert(t, &x509.Certificate{
+				Subject:               pkix.Name{CommonName: "Test CA"},
+				IsCA:                  true,
+				BasicConstraintsValid: true,
+				MaxPathLen:            tc.maxPathLen,
+				MaxPathLenZero:        tc.zero,
+				KeyUsage:              x509.KeyUsageCertSign,
+			}, nil, nil)
+
+			e := newExtensions(ca.cert)
+			require.True(t, e.IsCA)
+			require.Equal(t, []string{"keyCertSign"}, e.KeyUsage)
+			require.Equal(t, tc.expected, e.MaxPathLen)
+		})
+	}
+}
+
+func TestNewCertificate_Extensions(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com")
+	file := writeTestFile(t, t.TempDir(), "cert.pem", pemEncodeCerts(leaf))
+
+	cert := NewCertificate(file)
+	require.Empty(t, cert.Error)
+	require.NotNil(t, cert.Extensions)
+	require.Equal(t, []string{"serverAuth"}, cert.Extensions.ExtKeyUsage)
+}
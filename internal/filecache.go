@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"container/list"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Defaults of the analysis cache.
+const (
+	DefaultCacheSize = 1000
+	DefaultCacheTTL  = time.Hour
+)
+
+// FileCache caches analysis results of files. An entry is only used while the file still has the device, inode,
+// size and modification time it had when it was analyzed, so replaced files and swapped symlinks are analyzed again.
+// A nil FileCache disables caching.
+type FileCache struct {
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Entries by type and path
+	lru     *list.List               // Entries from most to least recently used
+	hits    uint64
+	misses  uint64
+}
+
+// fileStamp identifies the content of a file without reading it.
+type fileStamp struct {
+	dev, ino uint64
+	size     int64
+	modTime  time.Time
+}
+
+// fileCacheEntry is a cached analysis result.
+type fileCacheEntry struct {
+	key      string
+	stamp    fileStamp
+	storedAt time.Time
+	value    any
+}
+
+// NewFileCache creates a cache holding at most maxEntries results, each for at most ttl.
+// It returns nil, i.e. no caching, if maxEntries is not positive.
+func NewFileCache(maxEntries int, ttl time.Duration) *FileCache {
+	if maxEntries <= 0 {
+		return nil
+	}
+	return &FileCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+// Cached returns the cached result of type T for the file, or calls load and caches its result.
+// Callers must not modify cached results; results are only stored if the file did not change while loading.
+func Cached[T any](c *FileCache, file string, now time.Time, load func() T) T {
+	if c == nil {
+		return load()
+	}
+
+	key := reflect.TypeFor[T]().String() + ":" + file
+	before, ok := statFile(file)
+	if !ok {
+		return load()
+	}
+	if value, hit := c.lookup(key, before, now); hit {
+		if v, isT := value.(T); isT {
+			return v
+		}
+	}
+
+	value := load()
+	if after, unchanged := statFile(file); unchanged && after == before {
+		c.store(key, before, now, value)
+	}
+	return value
+}
+
+// lookup returns the entry for the key if it matches the stamp and has not expired.
+func (c *FileCache) lookup(key string, stamp fileStamp, now time.Time) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*fileCacheEntry)
+		if entry.stamp == stamp && (c.ttl <= 0 || now.Sub(entry.storedAt) < c.ttl) {
+			c.lru.MoveToFront(elem)
+			c.hits++
+			return entry.value, true
+		}
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.misses++
+	return nil, false
+}
+
+// store adds or replaces the entry for the key and evicts the least recently used entries beyond the maximum.
+func (c *FileCache) store(key string, stamp fileStamp, now time.Time, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&fileCacheEntry{key: key, stamp: stamp, storedAt: now, value: value})
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*fileCacheEntry).key)
+	}
+}
+
+// Stats returns the number of cache hits and misses and the number of cached entries.
+func (c *FileCache) Stats() (hits, misses uint64, entries int) {
+	if c == nil {
+		return 0, 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses, c.lru.Len()
+}
+
+// Purge removes all entries.
+func (c *FileCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+}
+
+// statFile returns the stamp of the file, following symlinks.
+func statFile(file string) (fileStamp, bool) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	dev, ino := fileID(info)
+	return fileStamp{dev: dev, ino: ino, size: info.Size(), modTime: info.ModTime()}, true
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingLoader returns a load function returning the file content and counting its calls.
+func countingLoader(file string, calls *int) func() string {
+	return func() string {
+		*calls++
+		b, _ := os.ReadFile(file)
+		return string(b)
+	}
+}
+
+func TestCached(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "cert.pem", []byte("first"))
+	cache := NewFileCache(10, time.Hour)
+	now := time.Now()
+	calls := 0
+
+	require.Equal(t, "first", Cached(cache, file, now, countingLoader(file, &calls)))
+	require.Equal(t, "first", Cached(cache, file, now, countingLoader(file, &calls)))
+	require.Equal(t, 1, calls)
+
+	// Replacing the file changes inode, size and modification time
+	writeTestFile(t, dir, "new.pem", []byte("second!"))
+	require.NoError(t, os.Rename(filepath.Join(dir, "new.pem"), file))
+	require.Equal(t, "second!", Cached(cache, file, now, countingLoader(file, &calls)))
+	require.Equal(t, 2, calls)
+
+	// Results of different types are cached separately
+	require.Equal(t, 7, Cached(cache, file, now, func() int { return 7 }))
+
+	hits, misses, entries := cache.Stats()
+	require.Equal(t, uint64(1), hits)
+	require.Equal(t, uint64(3), misses)
+	require.Equal(t, 2, entries)
+}
+
+func TestCached_SymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "cert-1600000000.pem", []byte("old"))
+	writeTestFile(t, dir, "cert-1700000000.pem", []byte("new"))
+	link := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.Symlink("cert-1600000000.pem", link))
+	cache := NewFileCache(10, time.Hour)
+	now := time.Now()
+	calls := 0
+
+	require.Equal(t, "old", Cached(cache, link, now, countingLoader(link, &calls)))
+
+	// dehydrated replaces the symlink during a renewal
+	require.NoError(t, os.Symlink("cert-1700000000.pem", filepath.Join(dir, "cert.pem.tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "cert.pem.tmp"), link))
+	require.Equal(t, "new", Cached(cache, link, now, countingLoader(link, &calls)))
+	require.Equal(t, 2, calls)
+}
+
+func TestCached_TTL(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "cert.pem", []byte("content"))
+	cache := NewFileCache(10, time.Minute)
+	now := time.Now()
+	calls := 0
+
+	Cached(cache, file, now, countingLoader(file, &calls))
+	Cached(cache, file, now.Add(59*time.Second), countingLoader(file, &calls))
+	require.Equal(t, 1, calls)
+	Cached(cache, file, now.Add(time.Minute), countingLoader(file, &calls))
+	require.Equal(t, 2, calls)
+}
+
+func TestCached_Eviction(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.pem", []byte("a"))
+	b := writeTestFile(t, dir, "b.pem", []byte("b"))
+	c := writeTestFile(t, dir, "c.pem", []byte("c"))
+	cache := NewFileCache(2, time.Hour)
+	now := time.Now()
+	calls := 0
+
+	Cached(cache, a, now, countingLoader(a, &calls))
+	Cached(cache, b, now, countingLoader(b, &calls))
+	Cached(cache, a, now, countingLoader(a, &calls)) // a is now the most recently used entry
+	Cached(cache, c, now, countingLoader(c, &calls)) // evicts b
+	require.Equal(t, 3, calls)
+
+	Cached(cache, a, now, countingLoader(a, &calls))
+	require.Equal(t, 3, calls)
+	Cached(cache, b, now, countingLoader(b, &calls))
+	require.Equal(t, 4, calls)
+
+	_, _, entries := cache.Stats()
+	require.Equal(t, 2, entries)
+	cache.Purge()
+	_, _, entries = cache.Stats()
+	require.Zero(t, entries)
+}
+
+func TestCached_ChangedWhileLoading(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "cert.pem", []byte("first"))
+	cache := NewFileCache(10, time.Hour)
+	now := time.Now()
+
+	require.Equal(t, "first", Cached(cache, file, now, func() string {
+		require.NoError(t, os.WriteFile(file, []byte("second!"), 0600))
+		return "first"
+	}))
+	_, _, entries := cache.Stats()
+	require.Zero(t, entries)
+}
+
+func TestCached_Disabled(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "cert.pem", []byte("content"))
+	cache := NewFileCache(0, time.Hour)
+	require.Nil(t, cache)
+	calls := 0
+
+	Cached(cache, file, time.Now(), countingLoader(file, &calls))
+	Cached(cache, file, time.Now(), countingLoader(file, &calls))
+	require.Equal(t, 2, calls)
+
+	hits, misses, entries := cache.Stats()
+	require.Zero(t, hits)
+	require.Zero(t, misses)
+	require.Zero(t, entries)
+	cache.Purge()
+}
+
+func TestCached_NonExistentFile(t *testing.T) {
+	cache := NewFileCache(10, time.Hour)
+	calls := 0
+
+	Cached(cache, "nonexistent", time.Now(), func() string { calls++; return "" })
+	Cached(cache, "nonexistent", time.Now(), func() string { calls++; return "" })
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultKeyMaxMode is the most permissive mode a private key file may have without a warning.
+// dehydrated creates private keys with umask 077, i.e. mode 0600.
+const DefaultKeyMaxMode os.FileMode = 0600
+
+// Read permission bits of the owning group and of everybody else.
+const (
+	modeGroupRead os.FileMode = 0040
+	modeWorldRead os.FileMode = 0004
+)
+
+// FileSecurity describes the permissions and ownership of a file, e.g. of a private key.
+type FileSecurity struct {
+	Mode          string   `json:"mode"`               // Permission bits in octal, e.g. 0600
+	UID           *int     `json:"uid,omitempty"`      // Owner user ID, not available on Windows
+	GID           *int     `json:"gid,omitempty"`      // Owner group ID, not available on Windows
+	GroupReadable bool     `json:"group_readable"`     // Whether members of the owning group can read the file
+	WorldReadable bool     `json:"world_readable"`     // Whether everybody can read the file
+	Symlink       bool     `json:"symlink"`            // Whether the file is a symlink
+	Target        string   `json:"target,omitempty"`   // Resolved path of a symlink
+	OutsideDir    bool     `json:"outside_dir"`        // Whether a symlink resolves to a file outside the directory of the file
+	Warnings      []string `json:"warnings,omitempty"` // Violations of the file security policy
+	Error         string   `json:"error,omitempty"`    // Error represents any error encountered during file security analysis.
+}
+
+// NewFileSecurity creates a new FileSecurity instance by inspecting the provided file.
+// A warning is recorded if the mode grants permissions beyond maxMode; a zero maxMode disables this check.
+func NewFileSecurity(file string, maxMode os.FileMode) *FileSecurity {
+	s := &FileSecurity{}
+	err := s.analyze(file, maxMode)
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	return s
+}
+
+// analyze inspects the file and its symlink target and applies the policy.
+func (s *FileSecurity) analyze(file string, maxMode os.FileMode) error {
+	linfo, err := os.Lstat(file)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", file, err)
+	}
+	if linfo.Mode()&os.ModeSymlink != 0 {
+		s.Symlink = true
+		if err = s.resolve(file); err != nil {
+			return err
+		}
+	}
+
+	// Permissions and ownership of the file that is actually read
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", file, err)
+	}
+	perm := info.Mode().Perm()
+	s.Mode = fmt.Sprintf("%04o", perm)
+	if uid, gid, ok := fileOwner(info); ok {
+		s.UID, s.GID = &uid, &gid
+	}
+	s.GroupReadable = perm&modeGroupRead != 0
+	s.WorldReadable = perm&modeWorldRead != 0
+
+	if maxMode != 0 && perm&^maxMode != 0 {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("mode %s exceeds the maximum %04o", s.Mode, maxMode.Perm()))
+	}
+	if s.OutsideDir {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("symlink resolves to %s outside of %s", s.Target, filepath.Dir(file)))
+	}
+
+	return nil
+}
+
+// resolve sets the target of a symlink and whether it lies outside the directory containing the symlink.
+func (s *FileSecurity) resolve(file string) error {
+	target, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+	s.Target = target
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(file))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", filepath.Dir(file), err)
+	}
+	rel, err := filepath.Rel(dir, target)
+	s.OutsideDir = err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package internal
+
+import "os"
+
+// fileOwner is not supported on platforms without POSIX ownership.
+func fileOwner(_ os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// fileID is not supported on platforms without inode numbers; the cache relies on size and modification time there.
+func fileID(_ os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
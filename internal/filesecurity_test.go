@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFileSecurity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not available on Windows")
+	}
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "privkey.pem", []byte("key"))
+
+	testCases := []struct {
+		name          string
+		mode          os.FileMode
+		groupReadable bool
+		worldReadable bool
+		warnings      []string
+	}{
+		{"OwnerOnly", 0600, false, false, nil},
+		{"ReadOnly", 0400, false, false, nil},
+		{"GroupReadable", 0640, true, false, []string{"mode 0640 exceeds the maximum 0600"}},
+		{"WorldReadable", 0644, true, true, []string{"mode 0644 exceeds the maximum 0600"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, os.Chmod(file, tc.mode))
+
+			s := NewFileSecurity(file, DefaultKeyMaxMode)
+			require.Empty(t, s.Error)
+			require.Equal(t, tc.groupReadable, s.GroupReadable)
+			require.Equal(t, tc.worldReadable, s.WorldReadable)
+			require.Equal(t, tc.warnings, s.Warnings)
+			require.False(t, s.Symlink)
+			require.NotNil(t, s.UID)
+			require.Equal(t, os.Getuid(), *s.UID)
+			require.Equal(t, os.Getgid(), *s.GID)
+		})
+	}
+
+	require.NoError(t, os.Chmod(file, 0644))
+	require.Empty(t, NewFileSecurity(file, 0).Warnings)
+	require.Empty(t, NewFileSecurity(file, 0644).Warnings)
+}
+
+func TestNewFileSecurity_Symlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on Windows")
+	}
+	outside := writeTestFile(t, t.TempDir(), "stolen.pem", []byte("key"))
+	dir := t.TempDir()
+	writeTestFile(t, dir, "privkey-1700000000.pem", []byte("key"))
+	require.NoError(t, os.Symlink("privkey-1700000000.pem", filepath.Join(dir, "privkey.pem")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "outside.pem")))
+
+	s := NewFileSecurity(filepath.Join(dir, "privkey.pem"), DefaultKeyMaxMode)
+	require.Empty(t, s.Error)
+	require.True(t, s.Symlink)
+	require.Equal(t, "privkey-1700000000.pem", filepath.Base(s.Target))
+	require.False(t, s.OutsideDir)
+	require.Empty(t, s.Warnings)
+
+	s = NewFileSecurity(filepath.Join(dir, "outside.pem"), DefaultKeyMaxMode)
+	require.Empty(t, s.Error)
+	require.True(t, s.OutsideDir)
+	require.Len(t, s.Warnings, 1)
+	require.Contains(t, s.Warnings[0], "outside of")
+
+	require.NoError(t, os.Symlink("missing.pem", filepath.Join(dir, "dangling.pem")))
+	s = NewFileSecurity(filepath.Join(dir, "dangling.pem"), DefaultKeyMaxMode)
+	require.Contains(t, s.Error, "failed to resolve")
+}
+
+func TestNewFileSecurity_NonExistentFile(t *testing.T) {
+	s := NewFileSecurity("nonexistent", DefaultKeyMaxMode)
+	require.Contains(t, s.Error, "failed to stat")
+}
+
+func TestNewKey_FileSecurity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not available on Windows")
+	}
+	file := writeTestFile(t, t.TempDir(), "privkey.pem", pemEncodeKey(t, newTestKey(t)))
+	require.NoError(t, os.Chmod(file, 0640))
+
+	key := NewKey(file, WithMaxMode(DefaultKeyMaxMode))
+	require.Empty(t, key.Error)
+	require.NotNil(t, key.FileSecurity)
+	require.Equal(t, "0640", key.FileSecurity.Mode)
+	require.Len(t, key.FileSecurity.Warnings, 1)
+
+	key = NewKey(file)
+	require.Empty(t, key.FileSecurity.Warnings)
+
+	require.Nil(t, NewKey("nonexistent").FileSecurity)
+}
+
+func TestKey_RefreshFileSecurity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions are not available on Windows")
+	}
+	file := writeTestFile(t, t.TempDir(), "privkey.pem", pemEncodeKey(t, newTestKey(t)))
+	key := NewKey(file, WithMaxMode(DefaultKeyMaxMode))
+	require.Empty(t, key.FileSecurity.Warnings)
+
+	require.NoError(t, os.Chmod(file, 0644))
+	clone := key.Clone()
+	clone.RefreshFileSecurity()
+	require.Len(t, clone.FileSecurity.Warnings, 1)
+	require.Empty(t, key.FileSecurity.Warnings)
+
+	missing := NewKey("nonexistent")
+	missing.RefreshFileSecurity()
+	require.Nil(t, missing.FileSecurity)
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/sha1" //nolint:gosec // SHA-1 is only used to compute the well-known certificate fingerprint
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+// Fingerprints holds the identifying hashes of a certificate.
+// Every hash is reported in OpenSSL colon separated hex notation as well as in standard base64.
+type Fingerprints struct {
+	SHA1             string `json:"sha1"`               // SHA-1 of the DER encoded certificate
+	SHA1Base64       string `json:"sha1_base64"`        // SHA-1 of the DER encoded certificate, base64 encoded
+	SHA256           string `json:"sha256"`             // SHA-256 of the DER encoded certificate
+	SHA256Base64     string `json:"sha256_base64"`      // SHA-256 of the DER encoded certificate, base64 encoded
+	SPKISHA256       string `json:"spki_sha256"`        // SHA-256 of the DER encoded SubjectPublicKeyInfo
+	SPKISHA256Base64 string `json:"spki_sha256_base64"` // SHA-256 of the SubjectPublicKeyInfo, base64 encoded (pin-sha256)
+}
+
+// newFingerprints computes the fingerprints of the given certificate.
+func newFingerprints(cert *x509.Certificate) *Fingerprints {
+	sha1Sum := sha1.Sum(cert.Raw) //nolint:gosec // see import
+	sha256Sum := sha256.Sum256(cert.Raw)
+	spkiSum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return &Fingerprints{
+		SHA1:             colonHex(sha1Sum[:]),
+		SHA1Base64:       base64.StdEncoding.EncodeToString(sha1Sum[:]),
+		SHA256:           colonHex(sha256Sum[:]),
+		SHA256Base64:     base64.StdEncoding.EncodeToString(sha256Sum[:]),
+		SPKISHA256:       colonHex(spkiSum[:]),
+		SPKISHA256Base64: base64.StdEncoding.EncodeToString(spkiSum[:]),
+	}
+}
+
+// spkiFingerprint returns the SHA-256 hash of the DER encoded SubjectPublicKeyInfo of pub in colon separated hex.
+func spkiFingerprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return colonHex(sum[:]), nil
+}
+
+// formatSerial formats a certificate serial number the way OpenSSL prints it, as colon separated hex bytes.
+func formatSerial(serial *big.Int) string {
+	if serial == nil {
+		return ""
+	}
+	b := serial.Bytes()
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	s := colonHex(b)
+	if serial.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// colonHex encodes b as upper case hex with the bytes separated by colons, e.g. "AB:CD:EF".
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i := range b {
+		parts[i] = strings.ToUpper(hex.EncodeToString(b[i : i+1]))
+	}
+	return strings.Join(parts, ":")
+}
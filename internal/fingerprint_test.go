@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFingerprints(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com")
+
+	fp := newFingerprints(leaf.cert)
+
+	sum := sha256.Sum256(leaf.cert.Raw)
+	require.Equal(t, colonHex(sum[:]), fp.SHA256)
+	require.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), fp.SHA256Base64)
+
+	spki := sha256.Sum256(leaf.cert.RawSubjectPublicKeyInfo)
+	require.Equal(t, colonHex(spki[:]), fp.SPKISHA256)
+	require.Equal(t, base64.StdEncoding.EncodeToString(spki[:]), fp.SPKISHA256Base64)
+
+	require.Len(t, fp.SHA1, 20*3-1)
+	require.Len(t, fp.SHA256, 32*3-1)
+}
+
+func TestSPKIFingerprint(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com")
+
+	fp, err := spkiFingerprint(leaf.key.Public())
+	require.NoError(t, err)
+	require.Equal(t, newFingerprints(leaf.cert).SPKISHA256, fp)
+
+	_, err = spkiFingerprint("not a key")
+	require.Error(t, err)
+}
+
+func TestNewKey_SPKIFingerprint(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com")
+	file := writeTestFile(t, t.TempDir(), "privkey.pem", pemEncodeKey(t, leaf.key))
+
+	key := NewKey(file)
+	require.Empty(t, key.Error)
+	require.Equal(t, newFingerprints(leaf.cert).SPKISHA256, key.SPKISHA256)
+}
+
+func TestFormatSerial(t *testing.T) {
+	testCases := []struct {
+		name     string
+		serial   *big.Int
+		expected string
+	}{
+		{"Nil", nil, ""},
+		{"Zero", big.NewInt(0), "00"},
+		{"Small", big.NewInt(0x0a), "0A"},
+		{"MultiByte", big.NewInt(0x03abcd), "03:AB:CD"},
+		{"Negative", big.NewInt(-0x10), "-10"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, formatSerial(tc.serial))
+		})
+	}
+}
+
+func TestColonHex(t *testing.T) {
+	require.Empty(t, colonHex(nil))
+	require.Equal(t, "DE:AD:BE:EF", colonHex([]byte{0xde, 0xad, 0xbe, 0xef}))
+}
+
+func TestNewCertificate_Fingerprints(t *testing.T) {
+	leaf := newTestLeaf(t, newTestCA(t, "Test Root", nil), "example.com")
+	file := writeTestFile(t, t.TempDir(), "cert.pem", pemEncodeCerts(leaf))
+
+	cert := NewCertificate(file)
+	require.Empty(t, cert.Error)
+	require.Equal(t, formatSerial(leaf.cert.SerialNumber), cert.SerialNumber)
+	require.NotNil(t, cert.Fingerprints)
+	require.Equal(t, newFingerprints(leaf.cert).SHA256, cert.Fingerprints.SHA256)
+}
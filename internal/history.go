@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// historyFilePattern matches the timestamped files dehydrated keeps for every issued certificate.
+var historyFilePattern = regexp.MustCompile(`^(cert|chain|fullchain|privkey)-(\d+)\.pem$`)
+
+// historyLinks are the plain file names dehydrated points at the newest timestamped files.
+var historyLinks = []string{"privkey.pem", "cert.pem", "chain.pem", "fullchain.pem"}
+
+// History lists every certificate version dehydrated keeps in a domain directory.
+type History struct {
+	Versions []*HistoryVersion `json:"versions"`           // Versions ordered from oldest to newest
+	Symlinks []*HistorySymlink `json:"symlinks,omitempty"` // Versions the plain file names resolve to
+	Error    string            `json:"error,omitempty"`    // Error represents any error encountered during history analysis.
+}
+
+// HistoryVersion is a single certificate version identified by the unix timestamp in its file names.
+type HistoryVersion struct {
+	Timestamp      int64     `json:"timestamp"`                 // Unix timestamp of the version
+	Time           time.Time `json:"time"`                      // Timestamp as time
+	Files          []string  `json:"files"`                     // Files present for this version, e.g. cert-1700000000.pem
+	SerialNumber   string    `json:"serial_number,omitempty"`   // Serial number of the certificate
+	NotBefore      time.Time `json:"not_before,omitempty"`      // Start of validity period of the certificate
+	NotAfter       time.Time `json:"not_after,omitempty"`       // End of validity period of the certificate
+	KeyFingerprint string    `json:"key_fingerprint,omitempty"` // SHA-256 SPKI fingerprint of the private key
+	Current        bool      `json:"current"`                   // Whether cert.pem resolves to this version
+	Errors         []string  `json:"errors,omitempty"`          // Errors encountered while analyzing the files of this version
+}
+
+// HistorySymlink describes which version a plain file name such as cert.pem resolves to.
+type HistorySymlink struct {
+	Name      string `json:"name"`                // Plain file name, e.g. cert.pem
+	Target    string `json:"target"`              // File the symlink points to
+	Timestamp int64  `json:"timestamp,omitempty"` // Timestamp of the target version, if the target is a timestamped file
+}
+
+// NewHistory creates a new History by scanning the domain directory for timestamped files.
+// The key options are used to analyze the timestamped private keys.
+func NewHistory(dir string, keyOpts ...KeyOption) *History {
+	h := &History{
+		Versions: []*HistoryVersion{}, // Always initialize to empty slice
+	}
+	err := h.analyze(dir, keyOpts)
+	if err != nil {
+		h.Error = err.Error()
+	}
+
+	return h
+}
+
+// analyze groups the timestamped files by version and analyzes the certificate and key of each version.
+func (h *History) analyze(dir string, keyOpts []KeyOption) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	versions := make(map[int64]*HistoryVersion)
+	for _, entry := range entries {
+		m := historyFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		ts, parseErr := strconv.ParseInt(m[2], 10, 64)
+		if parseErr != nil {
+			continue
+		}
+
+		v, ok := versions[ts]
+		if !ok {
+			v = &HistoryVersion{Timestamp: ts, Time: time.Unix(ts, 0).UTC()}
+			versions[ts] = v
+			h.Versions = append(h.Versions, v)
+		}
+		v.Files = append(v.Files, entry.Name())
+
+		switch m[1] {
+		case "cert":
+			v.describeCertificate(NewCertificate(filepath.Join(dir, entry.Name())))
+		case "privkey":
+			v.describeKey(NewKey(filepath.Join(dir, entry.Name()), keyOpts...))
+		}
+	}
+
+	sort.Slice(h.Versions, func(i, j int) bool {
+		return h.Versions[i].Timestamp < h.Versions[j].Timestamp
+	})
+
+	for _, name := range historyLinks {
+		target, linkErr := os.Readlink(filepath.Join(dir, name))
+		if linkErr != nil {
+			continue // not a symlink
+		}
+		link := &HistorySymlink{Name: name, Target: target}
+		if m := historyFilePattern.FindStringSubmatch(filepath.Base(target)); m != nil {
+			link.Timestamp, _ = strconv.ParseInt(m[2], 10, 64)
+			if v, ok := versions[link.Timestamp]; ok && name == "cert.pem" {
+				v.Current = true
+			}
+		}
+		h.Symlinks = append(h.Symlinks, link)
+	}
+
+	return nil
+}
+
+// describeCertificate copies the identifying fields of the version's certificate.
+func (v *HistoryVersion) describeCertificate(cert *Certificate) {
+	if cert.Error != "" {
+		v.Errors = append(v.Errors, cert.Error)
+		return
+	}
+	v.SerialNumber = cert.SerialNumber
+	v.NotBefore = cert.NotBefore
+	v.NotAfter = cert.NotAfter
+}
+
+// describeKey copies the fingerprint of the version's private key.
+func (v *HistoryVersion) describeKey(key *Key) {
+	if key.Error != "" {
+		v.Errors = append(v.Errors, key.Error)
+		return
+	}
+	v.KeyFingerprint = key.SPKISHA256
+}
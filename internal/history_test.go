@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHistory(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	oldLeaf := newTestLeaf(t, root, "example.com")
+	newLeaf := newTestLeaf(t, root, "example.com")
+	dir := t.TempDir()
+
+	writeTestFile(t, dir, "cert-1700000000.pem", pemEncodeCerts(oldLeaf))
+	writeTestFile(t, dir, "privkey-1700000000.pem", pemEncodeKey(t, oldLeaf.key))
+	writeTestFile(t, dir, "cert-1710000000.pem", pemEncodeCerts(newLeaf))
+	writeTestFile(t, dir, "chain-1710000000.pem", pemEncodeCerts(root))
+	writeTestFile(t, dir, "privkey-1710000000.pem", pemEncodeKey(t, newLeaf.key))
+	writeTestFile(t, dir, "cert.csr", []byte("ignored"))
+	require.NoError(t, os.Symlink("cert-1710000000.pem", filepath.Join(dir, "cert.pem")))
+	require.NoError(t, os.Symlink("privkey-1710000000.pem", filepath.Join(dir, "privkey.pem")))
+	writeTestFile(t, dir, "chain.pem", pemEncodeCerts(root))
+
+	h := NewHistory(dir)
+	require.Empty(t, h.Error)
+	require.Len(t, h.Versions, 2)
+
+	v := h.Versions[0]
+	require.Equal(t, int64(1700000000), v.Timestamp)
+	require.Equal(t, time.Unix(1700000000, 0).UTC(), v.Time)
+	require.Equal(t, []string{"cert-1700000000.pem", "privkey-1700000000.pem"}, v.Files)
+	require.Equal(t, formatSerial(oldLeaf.cert.SerialNumber), v.SerialNumber)
+	require.True(t, oldLeaf.cert.NotAfter.Equal(v.NotAfter))
+	require.Equal(t, newFingerprints(oldLeaf.cert).SPKISHA256, v.KeyFingerprint)
+	require.False(t, v.Current)
+	require.Empty(t, v.Errors)
+
+	v = h.Versions[1]
+	require.Equal(t, int64(1710000000), v.Timestamp)
+	require.Len(t, v.Files, 3)
+	require.Equal(t, formatSerial(newLeaf.cert.SerialNumber), v.SerialNumber)
+	require.True(t, v.Current)
+
+	require.Equal(t, []*HistorySymlink{
+		{Name: "privkey.pem", Target: "privkey-1710000000.pem", Timestamp: 1710000000},
+		{Name: "cert.pem", Target: "cert-1710000000.pem", Timestamp: 1710000000},
+	}, h.Symlinks)
+}
+
+func TestNewHistory_InvalidVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "cert-1700000000.pem", []byte("invalid content"))
+
+	h := NewHistory(dir)
+	require.Empty(t, h.Error)
+	require.Len(t, h.Versions, 1)
+	require.Len(t, h.Versions[0].Errors, 1)
+	require.Contains(t, h.Versions[0].Errors[0], "failed to decode PEM block")
+}
+
+func TestNewHistory_NonExistentDirectory(t *testing.T) {
+	h := NewHistory("nonexistent")
+	require.Contains(t, h.Error, "failed to read")
+	require.NotNil(t, h.Versions)
+}
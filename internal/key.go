@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"crypto"
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
@@ -10,20 +12,98 @@ import (
 	"os"
 )
 
-// Key represents metadata and analysis results for a certificate file.
-// It holds metadata such as file path, type, size and potential errors during analysis.
+// Key algorithm names as reported in Key.Type.
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeX25519  = "x25519"
+	KeyTypeECDH    = "ecdh"
+)
+
+// Bit sizes of the Edwards and Montgomery curve keys, as reported by OpenSSL.
+const (
+	ed25519Bits = 256
+	x25519Bits  = 253
+
+	// curve25519SecurityBits is the security strength of both Ed25519 and X25519 keys.
+	curve25519SecurityBits = 128
+)
+
+// rsaSecurityStrengths lists the comparable strengths of RSA moduli from NIST SP 800-57 Part 1, table 2.
+var rsaSecurityStrengths = []struct {
+	size int
+	bits int
+}{
+	{15360, 256},
+	{7680, 192},
+	{3072, 128},
+	{2048, 112},
+	{1024, 80},
+}
+
+// curveSecurityStrengths lists the strengths of the NIST curves from NIST SP 800-57 Part 1, table 2.
+var curveSecurityStrengths = map[string]int{
+	"P-224": 112,
+	"P-256": 128,
+	"P-384": 192,
+	"P-521": 256,
+}
+
+// Key represents metadata and analysis results for a private key file.
+// It holds metadata such as file path, algorithm, size, curve, estimated security strength and potential errors during analysis.
 type Key struct {
-	File  string `json:"file"` // Path to the certificate file
-	Type  string `json:"type,omitempty"`
-	Size  int    `json:"size,omitempty"`
-	Error string `json:"error,omitempty"` // Error represents any error encountered during certificate analysis.
+	File           string         `json:"file"`                      // Path to the key file
+	Type           string         `json:"type,omitempty"`            // Key algorithm: rsa, ecdsa, ed25519, x25519 or ecdh
+	Size           int            `json:"size,omitempty"`            // Key size in bits
+	Curve          string         `json:"curve,omitempty"`           // Named curve for elliptic curve keys, e.g. P-256
+	PublicExponent int            `json:"public_exponent,omitempty"` // Public exponent of RSA keys
+	SecurityBits   int            `json:"security_bits,omitempty"`   // Estimated security strength in bits (NIST SP 800-57)
+	SPKISHA256     string         `json:"spki_sha256,omitempty"`     // SHA-256 of the public key's SubjectPublicKeyInfo in colon separated hex
+	Encrypted      bool           `json:"encrypted,omitempty"`       // Whether the key file is encrypted
+	Encryption     *KeyEncryption `json:"encryption,omitempty"`      // Encryption details of encrypted keys
+	FileSecurity   *FileSecurity  `json:"file_security,omitempty"`   // Permissions and ownership of the key file
+	Findings       []*KeyFinding  `json:"findings,omitempty"`        // Weaknesses found by the weak key checks
+	Error          string         `json:"error,omitempty"`           // Error represents any error encountered during key analysis.
+
+	publicKey  crypto.PublicKey // Public half of the analyzed key
+	passphrase []byte           // Passphrase used to decrypt encrypted keys
+	maxMode    os.FileMode      // Most permissive key file mode without a warning, zero disables the check
+	blocklist  *KeyBlocklist    // Fingerprints of known compromised keys
+}
+
+// KeyOption configures the analysis of a Key.
+type KeyOption func(*Key)
+
+// WithPassphrase sets the passphrase used to decrypt encrypted keys.
+func WithPassphrase(passphrase []byte) KeyOption {
+	return func(k *Key) {
+		k.passphrase = passphrase
+	}
+}
+
+// WithMaxMode sets the most permissive mode the key file may have without a file security warning.
+func WithMaxMode(mode os.FileMode) KeyOption {
+	return func(k *Key) {
+		k.maxMode = mode
+	}
+}
+
+// WithBlocklist sets the blocklist of known compromised keys the key is checked against.
+func WithBlocklist(blocklist *KeyBlocklist) KeyOption {
+	return func(k *Key) {
+		k.blocklist = blocklist
+	}
 }
 
 // NewKey creates and returns a new Key object by analyzing the provided file for key metadata and errors.
-func NewKey(file string) *Key {
+func NewKey(file string, opts ...KeyOption) *Key {
 	k := &Key{
 		File: file,
 	}
+	for _, opt := range opts {
+		opt(k)
+	}
 	err := k.analyze()
 	if err != nil {
 		k.Error = err.Error()
@@ -33,71 +113,174 @@ func NewKey(file string) *Key {
 }
 
 // analyze reads and parses the key file, determining its type and size, and sets the associated Key metadata.
-// Returns an error if the file cannot be read, decoded, or if the key type is unsupported.
+// Returns an error if the file cannot be read, decoded, decrypted, or if the key type is unsupported.
 func (k *Key) analyze() error {
 	data, err := os.ReadFile(k.File)
 	if err != nil {
 		return fmt.Errorf("failed to read %s: %w", k.File, err)
 	}
+	k.FileSecurity = NewFileSecurity(k.File, k.maxMode)
+
+	key, err := k.parse(data)
+	if err != nil {
+		return fmt.Errorf("%w for %s", err, k.File)
+	}
+	if key == nil {
+		return fmt.Errorf("unknown key format or unsupported key type for %s", k.File)
+	}
+
+	if signer, ok := key.(interface{ Public() crypto.PublicKey }); ok {
+		k.publicKey = signer.Public()
+		k.SPKISHA256, _ = spkiFingerprint(k.publicKey)
+		k.Findings = checkWeakKey(k.publicKey, k.SPKISHA256, k.blocklist)
+	}
+
+	return k.describe(key)
+}
+
+// PublicKey returns the public half of the analyzed key, or nil if the key could not be analyzed.
+func (k *Key) PublicKey() crypto.PublicKey {
+	return k.publicKey
+}
+
+// Clone returns a copy of the key whose file security can be refreshed independently of the original.
+func (k *Key) Clone() *Key {
+	clone := *k
+	return &clone
+}
 
-	// Parse all PEM blocks to find the actual key
-	var key any
-	var parseErr error
+// RefreshFileSecurity inspects the permissions and ownership of the key file again. Permission changes do not
+// modify the file's content or modification time, so they must be checked even if the key itself is unchanged.
+func (k *Key) RefreshFileSecurity() {
+	if k.FileSecurity != nil {
+		k.FileSecurity = NewFileSecurity(k.File, k.maxMode)
+	}
+}
 
+// parse returns the first private key found in the PEM encoded data, or nil if there is none.
+// Encrypted keys are described in the Key metadata and decrypted with the configured passphrase.
+func (k *Key) parse(data []byte) (any, error) {
 	for rest := data; len(rest) > 0; {
 		block, remaining := pem.Decode(rest)
 		if block == nil {
 			break
 		}
+		rest = remaining
 
 		// Skip EC PARAMETERS blocks
 		if block.Type == "EC PARAMETERS" {
-			rest = remaining
 			continue
 		}
 
-		// Try to parse the key
-		key, parseErr = x509.ParsePKCS8PrivateKey(block.Bytes)
-		if parseErr == nil {
-			break
+		if !isEncryptedBlock(block) {
+			if key := parsePrivateKeyDER(block.Bytes); key != nil {
+				return key, nil
+			}
+			continue
 		}
 
-		key, parseErr = x509.ParsePKCS1PrivateKey(block.Bytes) // RSA fallback
-		if parseErr == nil {
-			break
+		var der []byte
+		var err error
+		k.Encrypted = true
+		k.Encryption, der, err = decryptBlock(block, k.passphrase)
+		if err != nil {
+			return nil, err
 		}
 
-		key, parseErr = x509.ParseECPrivateKey(block.Bytes) // ECDSA fallback
-		if parseErr == nil {
-			break
+		// A wrong passphrase may still produce valid padding by chance
+		key := parsePrivateKeyDER(der)
+		if key == nil {
+			k.Encryption.Decrypted = false
+			return nil, errIncorrectPassphrase
 		}
-
-		rest = remaining
+		return key, nil
 	}
 
-	if key == nil {
-		return fmt.Errorf("unknown key format or unsupported key type for %s", k.File)
+	return nil, nil
+}
+
+// parsePrivateKeyDER parses a DER encoded private key in PKCS#8, PKCS#1 or SEC 1 format.
+func parsePrivateKeyDER(der []byte) any {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key
 	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil { // RSA fallback
+		return key
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil { // ECDSA fallback
+		return key
+	}
+	if key := parseWeakRSAPrivateKey(der); key != nil { // Weak RSA keys rejected by crypto/rsa
+		return key
+	}
+	return nil
+}
 
+// describe sets the algorithm, size, curve and security strength of the parsed key.
+func (k *Key) describe(key any) error {
 	switch r := key.(type) {
 	case *rsa.PrivateKey:
 		if r == nil {
 			return fmt.Errorf("parsed RSA key is nil for %s", k.File)
 		}
-		k.Type = "rsa"
+		k.Type = KeyTypeRSA
 		k.Size = r.N.BitLen()
+		k.PublicExponent = r.E
+		k.SecurityBits = rsaSecurityBits(k.Size)
 	case *ecdsa.PrivateKey:
 		if r == nil {
 			return fmt.Errorf("parsed ECDSA key is nil for %s", k.File)
 		}
-		k.Type = "ecdsa"
+		k.Type = KeyTypeECDSA
 		k.Size = r.Curve.Params().BitSize
+		k.Curve = r.Curve.Params().Name
+		k.SecurityBits = curveSecurityStrengths[k.Curve]
 	case ed25519.PrivateKey:
-		k.Type = "ecdsa"
-		k.Size = len(r)
+		k.Type = KeyTypeEd25519
+		k.Size = ed25519Bits
+		k.Curve = "Ed25519"
+		k.SecurityBits = curve25519SecurityBits
+	case *ecdh.PrivateKey:
+		if r == nil {
+			return fmt.Errorf("parsed ECDH key is nil for %s", k.File)
+		}
+		k.describeECDH(r)
 	default:
-		return fmt.Errorf("unknown key type %v", r)
+		return fmt.Errorf("unknown key type %T", r)
 	}
 
 	return nil
 }
+
+// describeECDH sets the metadata for ECDH keys, which Go returns for X25519 PKCS#8 keys.
+func (k *Key) describeECDH(key *ecdh.PrivateKey) {
+	k.Curve = fmt.Sprint(key.Curve())
+	if key.Curve() == ecdh.X25519() {
+		k.Type = KeyTypeX25519
+		k.Size = x25519Bits
+		k.SecurityBits = curve25519SecurityBits
+		return
+	}
+
+	k.Type = KeyTypeECDH
+	switch key.Curve() {
+	case ecdh.P256():
+		k.Size = 256
+	case ecdh.P384():
+		k.Size = 384
+	case ecdh.P521():
+		k.Size = 521
+	}
+	k.SecurityBits = curveSecurityStrengths[k.Curve]
+}
+
+// rsaSecurityBits estimates the security strength of an RSA modulus of the given size.
+// Moduli below 1024 bits are reported as 0.
+func rsaSecurityBits(size int) int {
+	for _, s := range rsaSecurityStrengths {
+		if size >= s.size {
+			return s.bits
+		}
+	}
+	return 0
+}
@@ -1,6 +1,15 @@
 package internal
 
 import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
 	"testing"
@@ -135,7 +144,7 @@ func TestKey_SupportedKeyTypes(t *testing.T) {
 	}{
 		{"RSA", "rsa", 2048, "rsa"},
 		{"ECDSA", "ecdsa", 256, "ecdsa"},
-		{"Ed25519", "ed25519", 32, "ed25519"}, // Note: Ed25519 is mapped to ecdsa in the code
+		{"Ed25519", "ed25519", 256, "ed25519"},
 	}
 
 	for _, tc := range testCases {
@@ -149,3 +158,88 @@ func TestKey_SupportedKeyTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestNewKey_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		key      any
+		expected *Key
+	}{
+		{"RSA", rsaKey, &Key{Type: KeyTypeRSA, Size: 2048, PublicExponent: 65537, SecurityBits: 112}},
+		{"ECDSA", p384Key, &Key{Type: KeyTypeECDSA, Size: 384, Curve: "P-384", SecurityBits: 192}},
+		{"ECDSA P-521", p521Key, &Key{Type: KeyTypeECDSA, Size: 521, Curve: "P-521", SecurityBits: 256}},
+		{"Ed25519", ed25519Key, &Key{Type: KeyTypeEd25519, Size: 256, Curve: "Ed25519", SecurityBits: 128}},
+		{"X25519", x25519Key, &Key{Type: KeyTypeX25519, Size: 253, Curve: "X25519", SecurityBits: 128}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(tc.key)
+			require.NoError(t, err)
+			file := writeTestFile(t, t.TempDir(), "privkey.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+			key := NewKey(file)
+			require.Empty(t, key.Error)
+			require.Equal(t, file, key.File)
+			require.Equal(t, tc.expected.Type, key.Type)
+			require.Equal(t, tc.expected.Size, key.Size)
+			require.Equal(t, tc.expected.Curve, key.Curve)
+			require.Equal(t, tc.expected.PublicExponent, key.PublicExponent)
+			require.Equal(t, tc.expected.SecurityBits, key.SecurityBits)
+			require.Equal(t, tc.key.(interface{ Public() crypto.PublicKey }).Public(), key.PublicKey())
+		})
+	}
+}
+
+func TestNewKey_PKCS1RSAKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	content := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	file := writeTestFile(t, t.TempDir(), "privkey.pem", content)
+
+	key := NewKey(file)
+	require.Empty(t, key.Error)
+	require.Equal(t, KeyTypeRSA, key.Type)
+	require.Equal(t, 1024, key.Size)
+	require.Equal(t, 80, key.SecurityBits)
+}
+
+func TestNewKey_SEC1ECKey(t *testing.T) {
+	ecKey := newTestKey(t).(*ecdsa.PrivateKey)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	file := writeTestFile(t, t.TempDir(), "privkey.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+	key := NewKey(file)
+	require.Empty(t, key.Error)
+	require.Equal(t, KeyTypeECDSA, key.Type)
+	require.Equal(t, "P-256", key.Curve)
+	require.Equal(t, 128, key.SecurityBits)
+}
+
+func TestKey_DescribeUnknownType(t *testing.T) {
+	key := &Key{File: "test.key"}
+	var unknown crypto.PrivateKey = "not a key"
+	require.ErrorContains(t, key.describe(unknown), "unknown key type")
+}
+
+func TestRSASecurityBits(t *testing.T) {
+	require.Equal(t, 0, rsaSecurityBits(512))
+	require.Equal(t, 80, rsaSecurityBits(1024))
+	require.Equal(t, 112, rsaSecurityBits(2048))
+	require.Equal(t, 128, rsaSecurityBits(3072))
+	require.Equal(t, 128, rsaSecurityBits(4096))
+	require.Equal(t, 192, rsaSecurityBits(7680))
+	require.Equal(t, 256, rsaSecurityBits(15360))
+}
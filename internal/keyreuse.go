@@ -0,0 +1,46 @@
+package internal
+
+import "errors"
+
+// KeyReuse reports whether a certificate private key is also used as an ACME account key.
+type KeyReuse struct {
+	Reused   bool               `json:"reused"`             // Whether the key is the key of at least one account
+	Accounts []*KeyReuseAccount `json:"accounts,omitempty"` // Accounts using the same key
+	Error    string             `json:"error,omitempty"`    // Error represents any error encountered during the comparison.
+}
+
+// KeyReuseAccount identifies an account whose key equals the certificate private key.
+type KeyReuseAccount struct {
+	Directory string `json:"directory"`        // Name of the account directory
+	CAURL     string `json:"ca_url,omitempty"` // CA directory URL of the account
+}
+
+// NewKeyReuse compares the SPKI fingerprint of the key with the keys of all accounts.
+func NewKeyReuse(key *Key, accounts *Accounts) *KeyReuse {
+	r := &KeyReuse{}
+	err := r.analyze(key, accounts)
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+// analyze records every account whose key has the SPKI fingerprint of the certificate private key.
+func (r *KeyReuse) analyze(key *Key, accounts *Accounts) error {
+	if key.SPKISHA256 == "" {
+		return errors.New("private key could not be analyzed")
+	}
+	if accounts.Error != "" {
+		return errors.New(accounts.Error)
+	}
+
+	for _, account := range accounts.Accounts {
+		if account.Key != nil && account.Key.SPKISHA256 == key.SPKISHA256 {
+			r.Accounts = append(r.Accounts, &KeyReuseAccount{Directory: account.Directory, CAURL: account.CAURL})
+		}
+	}
+	r.Reused = len(r.Accounts) > 0
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyReuse(t *testing.T) {
+	domainKey := newTestKey(t)
+	accountsDir := t.TempDir()
+	writeTestAccount(t, accountsDir, testCAURL, map[string][]byte{
+		"account_key.pem": pemEncodeKey(t, domainKey),
+	})
+	writeTestAccount(t, accountsDir, "https://acme-staging-v02.api.letsencrypt.org/directory", map[string][]byte{
+		"account_key.pem": pemEncodeKey(t, newTestKey(t)),
+	})
+	accounts := NewAccounts(accountsDir)
+
+	key := NewKey(writeTestFile(t, t.TempDir(), "privkey.pem", pemEncodeKey(t, domainKey)))
+	reuse := NewKeyReuse(key, accounts)
+	require.Empty(t, reuse.Error)
+	require.True(t, reuse.Reused)
+	require.Len(t, reuse.Accounts, 1)
+	require.Equal(t, testCAURL, reuse.Accounts[0].CAURL)
+
+	key = NewKey(writeTestFile(t, t.TempDir(), "privkey.pem", pemEncodeKey(t, newTestKey(t))))
+	reuse = NewKeyReuse(key, accounts)
+	require.Empty(t, reuse.Error)
+	require.False(t, reuse.Reused)
+	require.Empty(t, reuse.Accounts)
+}
+
+func TestNewKeyReuse_Errors(t *testing.T) {
+	reuse := NewKeyReuse(NewKey("nonexistent"), NewAccounts(t.TempDir()))
+	require.Equal(t, "private key could not be analyzed", reuse.Error)
+
+	key := NewKey(writeTestFile(t, t.TempDir(), "privkey.pem", pemEncodeKey(t, newTestKey(t))))
+	reuse = NewKeyReuse(key, NewAccounts("nonexistent"))
+	require.Contains(t, reuse.Error, "failed to read")
+	require.False(t, reuse.Reused)
+}
@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// metricsNamespace prefixes the names of all exported metrics.
+	metricsNamespace = "dehydrated_openssl"
+
+	// metricsReadHeaderTimeout limits the time a scrape may take to send its request headers.
+	metricsReadHeaderTimeout = 10 * time.Second
+
+	// metricsShutdownTimeout limits the time running scrapes get to finish when the server is closed.
+	metricsShutdownTimeout = 5 * time.Second
+)
+
+// Metrics holds the Prometheus metrics derived from the analyses of GetMetadata.
+// The gauges are labeled with the domain directory name and the base name of the analyzed file.
+// A nil Metrics disables recording.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	certificateExpiry *expiryCollector
+	keySize           *prometheus.GaugeVec
+	keyMatch          *prometheus.GaugeVec
+	analysisError     *prometheus.GaugeVec
+	calls             prometheus.Counter
+	duration          prometheus.Histogram
+}
+
+// NewMetrics creates the metrics in a registry of their own.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry:          prometheus.NewRegistry(),
+		certificateExpiry: newExpiryCollector(),
+		keySize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "key_size_bits",
+			Help:      "Size of the private key in bits.",
+		}, []string{"domain", "file"}),
+		keyMatch: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "key_matches_certificate",
+			Help:      "Whether privkey.pem is the private key of cert.pem (1) or not (0).",
+		}, []string{"domain"}),
+		analysisError: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "analysis_error",
+			Help:      "Whether the analysis of the file failed (1) or not (0).",
+		}, []string{"domain", "file"}),
+		calls: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "get_metadata_calls_total",
+			Help:      "Number of GetMetadata calls.",
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "get_metadata_duration_seconds",
+			Help:      "Duration of GetMetadata calls in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+	m.registry.MustRegister(m.certificateExpiry, m.keySize, m.keyMatch, m.analysisError, m.calls, m.duration)
+
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveCall counts a GetMetadata call and its duration.
+func (m *Metrics) ObserveCall(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.calls.Inc()
+	m.duration.Observe(d.Seconds())
+}
+
+// ObserveDomain records the analyses of the files of a domain directory. Series of values that are not known
+// anymore, e.g. the expiry of a certificate that can no longer be parsed, are removed.
+func (m *Metrics) ObserveDomain(domain string, key *Key, cert *Certificate, chain, fullchain *Chain,
+	consistency *Consistency) {
+	if m == nil {
+		return
+	}
+
+	keyFile := filepath.Base(key.File)
+	m.analysisError.WithLabelValues(domain, keyFile).Set(boolValue(key.Error != ""))
+	if key.Size > 0 {
+		m.keySize.WithLabelValues(domain, keyFile).Set(float64(key.Size))
+	} else {
+		m.keySize.DeleteLabelValues(domain, keyFile)
+	}
+
+	certFile := filepath.Base(cert.File)
+	m.analysisError.WithLabelValues(domain, certFile).Set(boolValue(cert.Error != ""))
+	m.certificateExpiry.observe(domain, certFile, []*Certificate{cert})
+
+	for _, c := range []*Chain{chain, fullchain} {
+		file := filepath.Base(c.File)
+		failed := c.Error != ""
+		certs := make([]*Certificate, 0, len(c.Certificates))
+		for _, entry := range c.Certificates {
+			failed = failed || entry.Error != ""
+			certs = append(certs, entry.Certificate)
+		}
+		m.analysisError.WithLabelValues(domain, file).Set(boolValue(failed))
+		m.certificateExpiry.observe(domain, file, certs)
+	}
+
+	if consistency.KeyMatchesCertificate != nil {
+		m.keyMatch.WithLabelValues(domain).Set(boolValue(*consistency.KeyMatchesCertificate))
+	} else {
+		m.keyMatch.DeleteLabelValues(domain)
+	}
+}
+
+// expiryCollector exports the earliest NotAfter of the certificates in each file. The seconds until then are
+// computed when the metrics are collected, so they keep decreasing between GetMetadata calls.
+type expiryCollector struct {
+	mu       sync.Mutex
+	notAfter map[expiryLabels]time.Time // Earliest NotAfter by domain and file
+
+	now          func() time.Time // Clock of the collection, replaced in tests
+	notAfterDesc *prometheus.Desc
+	expiryDesc   *prometheus.Desc
+}
+
+// expiryLabels are the label values of the expiry metrics.
+type expiryLabels struct {
+	domain string
+	file   string
+}
+
+// newExpiryCollector creates a collector without certificates.
+func newExpiryCollector() *expiryCollector {
+	labels := []string{"domain", "file"}
+	return &expiryCollector{
+		notAfter: map[expiryLabels]time.Time{},
+		now:      time.Now,
+		notAfterDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "certificate_not_after_timestamp_seconds"),
+			"Unix time of the earliest NotAfter of the certificates in the file.",
+			labels, nil),
+		expiryDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "certificate_expiry_seconds"),
+			"Seconds until the earliest NotAfter of the certificates in the file, negative once expired.",
+			labels, nil),
+	}
+}
+
+// observe records the earliest NotAfter of the parsed certificates, or removes the file if none could be parsed.
+func (c *expiryCollector) observe(domain, file string, certs []*Certificate) {
+	var earliest time.Time
+	for _, cert := range certs {
+		if cert.X509() == nil {
+			continue
+		}
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if earliest.IsZero() {
+		delete(c.notAfter, expiryLabels{domain, file})
+		return
+	}
+	c.notAfter[expiryLabels{domain, file}] = earliest
+}
+
+// Describe implements prometheus.Collector.
+func (c *expiryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.notAfterDesc
+	ch <- c.expiryDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *expiryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for labels, notAfter := range c.notAfter {
+		ch <- prometheus.MustNewConstMetric(c.notAfterDesc, prometheus.GaugeValue,
+			float64(notAfter.Unix()), labels.domain, labels.file)
+		ch <- prometheus.MustNewConstMetric(c.expiryDesc, prometheus.GaugeValue,
+			notAfter.Sub(now).Seconds(), labels.domain, labels.file)
+	}
+}
+
+// boolValue converts a boolean to the 1 or 0 of a Prometheus gauge.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// MetricsServer serves the metrics on /metrics.
+type MetricsServer struct {
+	server   *http.Server
+	listener net.Listener
+	done     chan struct{}
+}
+
+// StartMetricsServer listens on addr and serves the metrics in the background until Close is called.
+func StartMetricsServer(addr string, metrics *Metrics, logger hclog.Logger) (*MetricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	s := &MetricsServer{
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: metricsReadHeaderTimeout,
+		},
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		if serveErr := s.server.Serve(listener); !errors.Is(serveErr, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", "error", serveErr)
+		}
+	}()
+	logger.Info("Serving metrics", "address", s.Addr())
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on, e.g. to find the port chosen for ":0".
+func (s *MetricsServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server, giving running scrapes a moment to finish.
+func (s *MetricsServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	<-s.done
+	return err
+}
@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_ObserveDomain(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+	key, cert, chain, fullchain := writeDomainFiles(t,
+		pemEncodeKey(t, leaf.key),
+		pemEncodeCerts(leaf),
+		pemEncodeCerts(intermediate),
+		pemEncodeCerts(leaf, intermediate),
+	)
+
+	m := NewMetrics()
+	now := time.Now()
+	m.certificateExpiry.now = func() time.Time { return now }
+	m.ObserveDomain("example.com", key, cert, chain, fullchain, NewConsistency(key, cert, chain, fullchain))
+
+	require.InDelta(t, 256, testutil.ToFloat64(m.keySize.WithLabelValues("example.com", "privkey.pem")), 0)
+	require.InDelta(t, 1, testutil.ToFloat64(m.keyMatch.WithLabelValues("example.com")), 0)
+	require.InDelta(t, leaf.cert.NotAfter.Sub(now).Seconds(),
+		gaugeValue(t, m, "dehydrated_openssl_certificate_expiry_seconds", "example.com", "cert.pem"), 0.001)
+	require.InDelta(t, intermediate.cert.NotAfter.Sub(now).Seconds(),
+		gaugeValue(t, m, "dehydrated_openssl_certificate_expiry_seconds", "example.com", "chain.pem"), 0.001)
+	require.InDelta(t, float64(leaf.cert.NotAfter.Unix()),
+		gaugeValue(t, m, "dehydrated_openssl_certificate_not_after_timestamp_seconds", "example.com", "fullchain.pem"), 0)
+	for _, file := range []string{"privkey.pem", "cert.pem", "chain.pem", "fullchain.pem"} {
+		require.InDelta(t, 0, testutil.ToFloat64(m.analysisError.WithLabelValues("example.com", file)), 0, file)
+	}
+}
+
+func TestMetrics_ObserveDomain_Errors(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+	key, cert, chain, fullchain := writeDomainFiles(t,
+		pemEncodeKey(t, leaf.key),
+		pemEncodeCerts(leaf),
+		pemEncodeCerts(root),
+		pemEncodeCerts(leaf, root),
+	)
+
+	m := NewMetrics()
+	m.ObserveDomain("example.com", key, cert, chain, fullchain, NewConsistency(key, cert, chain, fullchain))
+	require.Equal(t, 3, testutil.CollectAndCount(m.certificateExpiry, "dehydrated_openssl_certificate_expiry_seconds"))
+	require.Equal(t, 1, testutil.CollectAndCount(m.keyMatch))
+
+	// Files that can no longer be analyzed report an error and drop their values
+	dir := t.TempDir()
+	key = NewKey(writeTestFile(t, dir, "privkey.pem", []byte("invalid")))
+	cert = NewCertificate(writeTestFile(t, dir, "cert.pem", []byte("invalid")))
+	m.ObserveDomain("example.com", key, cert, chain, fullchain, NewConsistency(key, cert, chain, fullchain))
+
+	require.InDelta(t, 1, testutil.ToFloat64(m.analysisError.WithLabelValues("example.com", "privkey.pem")), 0)
+	require.InDelta(t, 1, testutil.ToFloat64(m.analysisError.WithLabelValues("example.com", "cert.pem")), 0)
+	require.Equal(t, 0, testutil.CollectAndCount(m.keySize))
+	require.Equal(t, 0, testutil.CollectAndCount(m.keyMatch))
+	require.Equal(t, 2, testutil.CollectAndCount(m.certificateExpiry, "dehydrated_openssl_certificate_expiry_seconds"))
+	require.Equal(t, 2, testutil.CollectAndCount(m.certificateExpiry, "dehydrated_openssl_certificate_not_after_timestamp_seconds"))
+}
+
+func TestMetrics_CertificateExpiry_Scrape(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+	key, cert, chain, fullchain := writeDomainFiles(t,
+		pemEncodeKey(t, leaf.key),
+		pemEncodeCerts(leaf),
+		pemEncodeCerts(root),
+		pemEncodeCerts(leaf, root),
+	)
+
+	m := NewMetrics()
+	now := time.Now()
+	m.certificateExpiry.now = func() time.Time { return now }
+	m.ObserveDomain("example.com", key, cert, chain, fullchain, NewConsistency(key, cert, chain, fullchain))
+	expiry := gaugeValue(t, m, "dehydrated_openssl_certificate_expiry_seconds", "example.com", "cert.pem")
+	notAfter := gaugeValue(t, m, "dehydrated_openssl_certificate_not_after_timestamp_seconds", "example.com", "cert.pem")
+
+	// A later scrape reports less time remaining without another observation
+	now = now.Add(time.Hour)
+	require.InDelta(t, expiry-3600,
+		gaugeValue(t, m, "dehydrated_openssl_certificate_expiry_seconds", "example.com", "cert.pem"), 0.001)
+	require.InDelta(t, notAfter,
+		gaugeValue(t, m, "dehydrated_openssl_certificate_not_after_timestamp_seconds", "example.com", "cert.pem"), 0)
+}
+
+// gaugeValue gathers the metrics and returns the value of the gauge with the domain and file labels.
+func gaugeValue(t *testing.T, m *Metrics, name, domain, file string) float64 {
+	t.Helper()
+	families, err := m.registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["domain"] == domain && labels["file"] == file {
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	require.Failf(t, "metric not found", "%s{domain=%q,file=%q}", name, domain, file)
+	return 0
+}
+
+func TestMetrics_ObserveCall(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveCall(50 * time.Millisecond)
+	m.ObserveCall(time.Second)
+
+	require.InDelta(t, 2, testutil.ToFloat64(m.calls), 0)
+
+	// A nil Metrics ignores the calls
+	var disabled *Metrics
+	disabled.ObserveCall(time.Second)
+	disabled.ObserveDomain("example.com", nil, nil, nil, nil, nil)
+}
+
+func TestStartMetricsServer(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveCall(time.Second)
+
+	s, err := StartMetricsServer("127.0.0.1:0", m, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	resp, err := scrape(t, s.Addr())
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "dehydrated_openssl_get_metadata_calls_total 1")
+	require.Contains(t, string(body), "dehydrated_openssl_get_metadata_duration_seconds_count 1")
+
+	require.NoError(t, s.Close())
+	resp, err = scrape(t, s.Addr())
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+	require.Error(t, err)
+}
+
+// scrape requests the metrics from the server listening on addr.
+func scrape(t *testing.T, addr string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://"+addr+"/metrics", http.NoBody)
+	require.NoError(t, err)
+	return http.DefaultClient.Do(req)
+}
+
+func TestStartMetricsServer_InvalidAddress(t *testing.T) {
+	_, err := StartMetricsServer("invalid:address:0", NewMetrics(), hclog.NewNullLogger())
+	require.ErrorContains(t, err, "failed to listen")
+}
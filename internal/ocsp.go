@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSP certificate status values as reported in OCSPStaple.Status.
+const (
+	OCSPStatusGood    = "good"
+	OCSPStatusRevoked = "revoked"
+	OCSPStatusUnknown = "unknown"
+)
+
+// ocspStatuses maps the ocsp package status values to their names.
+var ocspStatuses = map[int]string{
+	ocsp.Good:    OCSPStatusGood,
+	ocsp.Revoked: OCSPStatusRevoked,
+	ocsp.Unknown: OCSPStatusUnknown,
+}
+
+// revocationReasons maps the RFC 5280 CRLReason codes to their names.
+var revocationReasons = map[int]string{
+	ocsp.Unspecified:          "unspecified",
+	ocsp.KeyCompromise:        "keyCompromise",
+	ocsp.CACompromise:         "cACompromise",
+	ocsp.AffiliationChanged:   "affiliationChanged",
+	ocsp.Superseded:           "superseded",
+	ocsp.CessationOfOperation: "cessationOfOperation",
+	ocsp.CertificateHold:      "certificateHold",
+	ocsp.RemoveFromCRL:        "removeFromCRL",
+	ocsp.PrivilegeWithdrawn:   "privilegeWithdrawn",
+	ocsp.AACompromise:         "aACompromise",
+}
+
+// OCSPStaple represents the OCSP response dehydrated stores next to the certificate when OCSP_FETCH is enabled.
+type OCSPStaple struct {
+	File             string    `json:"file"`                        // Path to the OCSP response file
+	Target           string    `json:"target,omitempty"`            // File the ocsp.der symlink resolves to
+	Status           string    `json:"status,omitempty"`            // Certificate status: good, revoked or unknown
+	SerialNumber     string    `json:"serial_number,omitempty"`     // Serial number the response is about
+	ProducedAt       time.Time `json:"produced_at,omitempty"`       // Time the response was signed
+	ThisUpdate       time.Time `json:"this_update,omitempty"`       // Time the status was known to be correct
+	NextUpdate       time.Time `json:"next_update,omitempty"`       // Time newer status information will be available
+	RevokedAt        time.Time `json:"revoked_at,omitempty"`        // Revocation time of revoked certificates
+	RevocationReason string    `json:"revocation_reason,omitempty"` // Revocation reason of revoked certificates
+	Responder        string    `json:"responder,omitempty"`         // Responder name, or responder key hash in colon separated hex
+	MatchesSerial    bool      `json:"matches_serial"`              // Whether the response is about the serial number of cert.pem
+	SignatureValid   bool      `json:"signature_valid"`             // Whether the response is signed by the issuer of cert.pem
+	Stale            bool      `json:"stale"`                       // Whether NextUpdate has passed
+	Error            string    `json:"error,omitempty"`             // Error represents any error encountered during OCSP analysis.
+}
+
+// NewOCSPStaple creates a new OCSPStaple from the provided file and checks it against the certificate
+// and its issuer from the chain at the time now.
+func NewOCSPStaple(file string, cert *Certificate, chain *Chain, now time.Time) *OCSPStaple {
+	o := &OCSPStaple{
+		File: file,
+	}
+	err := o.analyze(cert, chain, now)
+	if err != nil {
+		o.Error = err.Error()
+	}
+
+	return o
+}
+
+// analyze reads and parses the OCSP response and compares it with the leaf certificate and its issuer.
+func (o *OCSPStaple) analyze(cert *Certificate, chain *Chain, now time.Time) error {
+	if target, err := filepath.EvalSymlinks(o.File); err == nil && target != o.File {
+		o.Target = filepath.Base(target)
+	}
+
+	der, err := os.ReadFile(o.File)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", o.File, err)
+	}
+
+	resp, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		return fmt.Errorf("failed to parse OCSP response %s: %w", o.File, err)
+	}
+
+	o.Status = ocspStatuses[resp.Status]
+	o.SerialNumber = formatSerial(resp.SerialNumber)
+	o.ProducedAt = resp.ProducedAt
+	o.ThisUpdate = resp.ThisUpdate
+	o.NextUpdate = resp.NextUpdate
+	if resp.Status == ocsp.Revoked {
+		o.RevokedAt = resp.RevokedAt
+		o.RevocationReason = revocationReasons[resp.RevocationReason]
+	}
+	o.Responder = responderName(resp)
+	o.Stale = !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate)
+
+	leaf := cert.X509()
+	if leaf == nil {
+		return fmt.Errorf("certificate could not be analyzed")
+	}
+	o.MatchesSerial = resp.SerialNumber != nil && resp.SerialNumber.Cmp(leaf.SerialNumber) == 0
+
+	issuer := findIssuer(leaf, chain)
+	if issuer == nil {
+		return fmt.Errorf("issuer of %s not found in chain", leaf.Subject)
+	}
+	if _, err = ocsp.ParseResponse(der, issuer); err != nil {
+		return fmt.Errorf("failed to verify OCSP response signature: %w", err)
+	}
+	o.SignatureValid = true
+
+	return nil
+}
+
+// responderName returns the responder distinguished name or, if the response identifies the responder by key,
+// the key hash in colon separated hex.
+func responderName(resp *ocsp.Response) string {
+	if len(resp.RawResponderName) == 0 {
+		return colonHex(resp.ResponderKeyHash)
+	}
+
+	var rdn pkix.RDNSequence
+	if _, err := asn1.Unmarshal(resp.RawResponderName, &rdn); err != nil {
+		return ""
+	}
+	var name pkix.Name
+	name.FillFromRDNSequence(&rdn)
+
+	return name.String()
+}
+
+// findIssuer returns the certificate of the chain whose subject is the issuer of cert.
+func findIssuer(cert *x509.Certificate, chain *Chain) *x509.Certificate {
+	for _, entry := range chain.Certificates {
+		if c := entry.X509(); c != nil && bytes.Equal(c.RawSubject, cert.RawIssuer) {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestOCSPResponse creates an OCSP response for leaf signed by the issuer.
+func newTestOCSPResponse(t *testing.T, issuer, leaf *testCert, template ocsp.Response) []byte {
+	t.Helper()
+	template.SerialNumber = leaf.cert.SerialNumber
+	if template.ThisUpdate.IsZero() {
+		template.ThisUpdate = time.Now().Add(-time.Hour)
+	}
+	if template.NextUpdate.IsZero() {
+		template.NextUpdate = time.Now().Add(72 * time.Hour)
+	}
+	der, err := ocsp.CreateResponse(issuer.cert, issuer.cert, template, issuer.key)
+	require.NoError(t, err)
+	return der
+}
+
+func TestNewOCSPStaple_Good(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+	dir := t.TempDir()
+
+	cert := NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf)))
+	chain := NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(intermediate)))
+	writeTestFile(t, dir, "ocsp-1700000000.der", newTestOCSPResponse(t, intermediate, leaf, ocsp.Response{Status: ocsp.Good}))
+	file := filepath.Join(dir, "ocsp.der")
+	require.NoError(t, os.Symlink("ocsp-1700000000.der", file))
+
+	o := NewOCSPStaple(file, cert, chain, time.Now())
+	require.Empty(t, o.Error)
+	require.Equal(t, "ocsp-1700000000.der", o.Target)
+	require.Equal(t, OCSPStatusGood, o.Status)
+	require.Equal(t, cert.SerialNumber, o.SerialNumber)
+	require.Equal(t, "CN=Test Intermediate", o.Responder)
+	require.True(t, o.MatchesSerial)
+	require.True(t, o.SignatureValid)
+	require.False(t, o.Stale)
+	require.False(t, o.ThisUpdate.IsZero())
+	require.False(t, o.NextUpdate.IsZero())
+}
+
+func TestNewOCSPStaple_RevokedAndStale(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+	dir := t.TempDir()
+
+	cert := NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf)))
+	chain := NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(root)))
+	file := writeTestFile(t, dir, "ocsp.der", newTestOCSPResponse(t, root, leaf, ocsp.Response{
+		Status:           ocsp.Revoked,
+		RevokedAt:        time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second),
+		RevocationReason: ocsp.KeyCompromise,
+		ThisUpdate:       time.Now().Add(-10 * 24 * time.Hour),
+		NextUpdate:       time.Now().Add(-3 * 24 * time.Hour),
+	}))
+
+	o := NewOCSPStaple(file, cert, chain, time.Now())
+	require.Empty(t, o.Error)
+	require.Empty(t, o.Target)
+	require.Equal(t, OCSPStatusRevoked, o.Status)
+	require.Equal(t, "keyCompromise", o.RevocationReason)
+	require.False(t, o.RevokedAt.IsZero())
+	require.True(t, o.Stale)
+}
+
+func TestNewOCSPStaple_OtherCertificate(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	otherRoot := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+	otherLeaf := newTestLeaf(t, otherRoot, "example.com")
+	dir := t.TempDir()
+
+	cert := NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf)))
+	chain := NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(root)))
+	file := writeTestFile(t, dir, "ocsp.der", newTestOCSPResponse(t, otherRoot, otherLeaf, ocsp.Response{Status: ocsp.Good}))
+
+	o := NewOCSPStaple(file, cert, chain, time.Now())
+	require.False(t, o.MatchesSerial)
+	require.False(t, o.SignatureValid)
+	require.Contains(t, o.Error, "failed to verify OCSP response signature")
+}
+
+func TestNewOCSPStaple_MissingIssuer(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+	dir := t.TempDir()
+
+	cert := NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf)))
+	file := writeTestFile(t, dir, "ocsp.der", newTestOCSPResponse(t, root, leaf, ocsp.Response{Status: ocsp.Good}))
+
+	o := NewOCSPStaple(file, cert, NewChain("nonexistent.pem"), time.Now())
+	require.True(t, o.MatchesSerial)
+	require.Contains(t, o.Error, "not found in chain")
+}
+
+func TestNewOCSPStaple_InvalidFile(t *testing.T) {
+	o := NewOCSPStaple("nonexistent.der", NewCertificate("nonexistent.crt"), NewChain("nonexistent.pem"), time.Now())
+	require.Contains(t, o.Error, "failed to read")
+
+	file := writeTestFile(t, t.TempDir(), "ocsp.der", []byte("invalid content"))
+	o = NewOCSPStaple(file, NewCertificate("nonexistent.crt"), NewChain("nonexistent.pem"), time.Now())
+	require.Contains(t, o.Error, "failed to parse OCSP response")
+}
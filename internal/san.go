@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"crypto/x509"
+	"strings"
+)
+
+// Subject alternative name types as reported in SubjectAltName.Type.
+const (
+	SANTypeDNS   = "dns"
+	SANTypeIP    = "ip"
+	SANTypeEmail = "email"
+	SANTypeURI   = "uri"
+)
+
+// SubjectAltName is a single subject alternative name of a certificate.
+type SubjectAltName struct {
+	Type     string `json:"type"`               // One of dns, ip, email or uri
+	Value    string `json:"value"`              // The name itself
+	Wildcard bool   `json:"wildcard,omitempty"` // Whether the name is a DNS wildcard such as *.example.com
+}
+
+// newSubjectAltNames returns every subject alternative name of the certificate, grouped by type
+// in the order DNS, IP, email, URI.
+func newSubjectAltNames(cert *x509.Certificate) []*SubjectAltName {
+	names := make([]*SubjectAltName, 0, len(cert.DNSNames)+len(cert.IPAddresses)+len(cert.EmailAddresses)+len(cert.URIs))
+
+	for _, name := range cert.DNSNames {
+		names = append(names, &SubjectAltName{Type: SANTypeDNS, Value: name, Wildcard: isWildcard(name)})
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, &SubjectAltName{Type: SANTypeIP, Value: ip.String()})
+	}
+	for _, email := range cert.EmailAddresses {
+		names = append(names, &SubjectAltName{Type: SANTypeEmail, Value: email})
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, &SubjectAltName{Type: SANTypeURI, Value: uri.String()})
+	}
+
+	return names
+}
+
+// isWildcard reports whether the DNS name is a wildcard name.
+func isWildcard(name string) bool {
+	return strings.HasPrefix(name, "*.")
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSubjectAltNames(t *testing.T) {
+	uri, err := url.Parse("spiffe://example.com/service")
+	require.NoError(t, err)
+
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "example.com"},
+		DNSNames:       []string{"example.com", "*.example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+		EmailAddresses: []string{"admin@example.com"},
+		URIs:           []*url.URL{uri},
+	}, nil, newTestCA(t, "Test Root", nil))
+
+	names := newSubjectAltNames(leaf.cert)
+	require.Equal(t, []*SubjectAltName{
+		{Type: SANTypeDNS, Value: "example.com"},
+		{Type: SANTypeDNS, Value: "*.example.com", Wildcard: true},
+		{Type: SANTypeIP, Value: "192.0.2.1"},
+		{Type: SANTypeIP, Value: "2001:db8::1"},
+		{Type: SANTypeEmail, Value: "admin@example.com"},
+		{Type: SANTypeURI, Value: "spiffe://example.com/service"},
+	}, names)
+}
+
+func TestNewCertificate_AllSANTypes(t *testing.T) {
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "example.com"},
+		DNSNames:       []string{"*.example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1")},
+		EmailAddresses: []string{"admin@example.com"},
+	}, nil, newTestCA(t, "Test Root", nil))
+	file := writeTestFile(t, t.TempDir(), "cert.pem", pemEncodeCerts(leaf))
+
+	cert := NewCertificate(file)
+	require.Empty(t, cert.Error)
+	require.Equal(t, []string{"*.example.com"}, cert.DNSNames)
+	require.Equal(t, []string{"192.0.2.1"}, cert.IPAddresses)
+	require.Equal(t, []string{"admin@example.com"}, cert.EmailAddresses)
+	require.Empty(t, cert.URIs)
+	require.Equal(t, 3, cert.SANCount)
+	require.True(t, cert.SANs[0].Wildcard)
+}
+
+func TestIsWildcard(t *testing.T) {
+	require.True(t, isWildcard("*.example.com"))
+	require.False(t, isWildcard("example.com"))
+	require.False(t, isWildcard("www.*.example.com"))
+}
@@ -0,0 +1,324 @@
+package internal
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSharedPrimesTTL is the time a shared prime scan is reused before the keys are scanned again.
+const DefaultSharedPrimesTTL = time.Hour
+
+// SharedPrimeScan is the result of a batch GCD over the RSA moduli of every private key in the certificate directory,
+// including the timestamped keys of former certificate versions.
+type SharedPrimeScan struct {
+	CertDir   string    // Scanned certificate directory
+	ScannedAt time.Time // Time of the scan
+	Moduli    int       // Number of distinct RSA moduli scanned
+	Error     string    // Error represents any error encountered during the scan.
+
+	moduli []*scannedModulus
+}
+
+// scannedModulus is a distinct RSA modulus together with every key file it was found in.
+type scannedModulus struct {
+	n          *big.Int
+	files      []string // Paths relative to the certificate directory
+	shared     bool     // Whether the modulus shares a prime with another modulus
+	sharedWith []string // Files of the moduli sharing a prime
+}
+
+// SharedPrimes reports the keys of a domain whose RSA modulus shares a prime with another key.
+// Such keys can be factored with a single GCD, e.g. because they were generated with too little entropy.
+type SharedPrimes struct {
+	Shared    bool              `json:"shared"`          // Whether any key of the domain shares a prime
+	Keys      []*SharedPrimeKey `json:"keys,omitempty"`  // Keys of the domain sharing a prime
+	Moduli    int               `json:"moduli"`          // Number of distinct RSA moduli in the scan
+	ScannedAt time.Time         `json:"scanned_at"`      // Time of the scan
+	Error     string            `json:"error,omitempty"` // Error represents any error encountered during the scan.
+}
+
+// SharedPrimeKey is a key file whose modulus shares a prime with the moduli of other key files.
+type SharedPrimeKey struct {
+	File       string   `json:"file"`        // Key file relative to the certificate directory
+	SharedWith []string `json:"shared_with"` // Key files sharing a prime, relative to the certificate directory
+}
+
+// NewSharedPrimeScan reads every privkey.pem and privkey-<timestamp>.pem below certDir and runs a batch GCD
+// over their RSA moduli. The key options are used to analyze the private keys.
+func NewSharedPrimeScan(certDir string, now time.Time, keyOpts ...KeyOption) *SharedPrimeScan {
+	s := &SharedPrimeScan{
+		CertDir:   certDir,
+		ScannedAt: now,
+	}
+	err := s.analyze(keyOpts)
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	return s
+}
+
+// analyze collects the distinct moduli and marks those sharing a prime.
+func (s *SharedPrimeScan) analyze(keyOpts []KeyOption) error {
+	files, err := privateKeyFiles(s.CertDir)
+	if err != nil {
+		return err
+	}
+
+	byModulus := map[string]*scannedModulus{}
+	for _, file := range files {
+		key := NewKey(filepath.Join(s.CertDir, file), keyOpts...)
+		pub, ok := key.PublicKey().(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		m, seen := byModulus[pub.N.String()]
+		if !seen {
+			m = &scannedModulus{n: pub.N}
+			byModulus[pub.N.String()] = m
+			s.moduli = append(s.moduli, m)
+		}
+		m.files = append(m.files, file)
+	}
+	s.Moduli = len(s.moduli)
+
+	moduli := make([]*big.Int, len(s.moduli))
+	for i, m := range s.moduli {
+		moduli[i] = m.n
+	}
+	var shared []*scannedModulus
+	for i, gcd := range batchGCD(moduli) {
+		if gcd.Cmp(big.NewInt(1)) != 0 {
+			s.moduli[i].shared = true
+			shared = append(shared, s.moduli[i])
+		}
+	}
+
+	// Pairwise GCDs among the few affected moduli tell which keys share a prime
+	gcd := new(big.Int)
+	for _, a := range shared {
+		for _, b := range shared {
+			if a != b && gcd.GCD(nil, nil, a.n, b.n).Cmp(big.NewInt(1)) != 0 {
+				a.sharedWith = append(a.sharedWith, b.files...)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Domain returns the shared prime report for the keys in the domain directory named dir.
+func (s *SharedPrimeScan) Domain(dir string) *SharedPrimes {
+	r := &SharedPrimes{
+		Moduli:    s.Moduli,
+		ScannedAt: s.ScannedAt,
+		Error:     s.Error,
+	}
+	prefix := dir + string(filepath.Separator)
+	for _, m := range s.moduli {
+		if !m.shared {
+			continue
+		}
+		for _, file := range m.files {
+			if strings.HasPrefix(file, prefix) {
+				r.Keys = append(r.Keys, &SharedPrimeKey{File: file, SharedWith: m.sharedWith})
+			}
+		}
+	}
+	r.Shared = len(r.Keys) > 0
+
+	return r
+}
+
+// privateKeyFiles lists the private key files of all domain directories relative to certDir.
+// Symlinks are skipped if their target is listed as well, e.g. privkey.pem pointing to privkey-<timestamp>.pem.
+func privateKeyFiles(certDir string) ([]string, error) {
+	domains, err := os.ReadDir(certDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", certDir, err)
+	}
+
+	var files []string
+	seen := map[string]bool{}
+	for _, domain := range domains {
+		if !domain.IsDir() {
+			continue
+		}
+		entries, readErr := os.ReadDir(filepath.Join(certDir, domain.Name()))
+		if readErr != nil {
+			continue
+		}
+		for _, entry := range entries {
+			m := historyFilePattern.FindStringSubmatch(entry.Name())
+			if entry.Name() != "privkey.pem" && (m == nil || m[1] != "privkey") {
+				continue
+			}
+			file := filepath.Join(domain.Name(), entry.Name())
+			resolved, evalErr := filepath.EvalSymlinks(filepath.Join(certDir, file))
+			if evalErr != nil || seen[resolved] {
+				continue
+			}
+			seen[resolved] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// batchGCD returns for every modulus the GCD with the product of all other moduli, using a product and a
+// remainder tree (Heninger et al., "Mining Your Ps and Qs"). The moduli must be distinct.
+func batchGCD(moduli []*big.Int) []*big.Int {
+	if len(moduli) == 0 {
+		return nil
+	}
+
+	// Product tree from the moduli up to their product
+	tree := [][]*big.Int{moduli}
+	for level := moduli; len(level) > 1; {
+		next := make([]*big.Int, (len(level)+1)/2)
+		for i := range next {
+			next[i] = new(big.Int).Set(level[2*i])
+			if 2*i+1 < len(level) {
+				next[i].Mul(next[i], level[2*i+1])
+			}
+		}
+		tree = append(tree, next)
+		level = next
+	}
+
+	// Remainder tree from the product down to the product modulo the square of each modulus
+	rems := tree[len(tree)-1]
+	for l := len(tree) - 2; l >= 0; l-- {
+		next := make([]*big.Int, len(tree[l]))
+		for i, v := range tree[l] {
+			sq := new(big.Int).Mul(v, v)
+			next[i] = new(big.Int).Mod(rems[i/2], sq)
+		}
+		rems = next
+	}
+
+	gcds := make([]*big.Int, len(moduli))
+	for i, n := range moduli {
+		q := new(big.Int).Div(rems[i], n)
+		gcds[i] = q.GCD(nil, nil, q, n)
+	}
+	return gcds
+}
+
+// SharedPrimeCache keeps the latest shared prime scan, so the keys are scanned once per TTL and not per domain.
+// Outdated scans are refreshed in the background and served until the new scan is done.
+type SharedPrimeCache struct {
+	ttl     time.Duration
+	keyOpts []KeyOption
+
+	mu         sync.Mutex
+	scan       *SharedPrimeScan
+	pending    map[string]*pendingScan // First scans of directories without a scan to serve in the meantime
+	outdated   bool                    // Whether the keys changed since the scan was started
+	refreshing bool                    // Whether a scan is running in the background
+	wg         sync.WaitGroup
+}
+
+// pendingScan is a running first scan of a directory. Concurrent calls for the directory wait for done.
+type pendingScan struct {
+	done     chan struct{}
+	scan     *SharedPrimeScan // Result of the scan, set before done is closed
+	outdated bool             // Whether the keys changed since the scan was started
+}
+
+// NewSharedPrimeCache creates a cache that reuses a scan for ttl. The key options are used to analyze the private keys.
+func NewSharedPrimeCache(ttl time.Duration, keyOpts ...KeyOption) *SharedPrimeCache {
+	return &SharedPrimeCache{ttl: ttl, keyOpts: keyOpts}
+}
+
+// Get returns the scan of certDir. Without a scan of certDir the keys are scanned right away, and concurrent calls
+// for certDir wait for that scan. A scan that is older than the TTL or invalidated is returned while it is refreshed
+// in the background.
+func (c *SharedPrimeCache) Get(certDir string, now time.Time) *SharedPrimeScan {
+	c.mu.Lock()
+	if c.scan != nil && c.scan.CertDir == certDir {
+		defer c.mu.Unlock()
+		if c.outdated || now.Sub(c.scan.ScannedAt) >= c.ttl || now.Before(c.scan.ScannedAt) {
+			c.refresh(certDir, now)
+		}
+		return c.scan
+	}
+
+	if p, ok := c.pending[certDir]; ok {
+		c.mu.Unlock()
+		<-p.done
+		return p.scan
+	}
+
+	// The scan runs without holding c.mu, so calls for the cached directory and Invalidate are not blocked by it
+	p := &pendingScan{done: make(chan struct{})}
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingScan)
+	}
+	c.pending[certDir] = p
+	c.mu.Unlock()
+
+	scan := NewSharedPrimeScan(certDir, now, c.keyOpts...)
+
+	c.mu.Lock()
+	delete(c.pending, certDir)
+	p.scan = scan
+	c.scan = scan
+	c.outdated = p.outdated
+	c.mu.Unlock()
+	close(p.done)
+	return scan
+}
+
+// Invalidate marks the cached scan as outdated, e.g. after the keys changed, and scans the keys again in the
+// background.
+func (c *SharedPrimeCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range c.pending {
+		p.outdated = true
+	}
+	if c.scan == nil {
+		return
+	}
+	c.outdated = true
+	c.refresh(c.scan.CertDir, time.Now())
+}
+
+// Wait blocks until the background scan, if any, is done.
+func (c *SharedPrimeCache) Wait() {
+	c.wg.Wait()
+}
+
+// refresh starts a background scan of certDir unless one is running already. The caller must hold c.mu.
+func (c *SharedPrimeCache) refresh(certDir string, now time.Time) {
+	if c.refreshing {
+		return
+	}
+	c.refreshing = true
+	c.outdated = false
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		scan := NewSharedPrimeScan(certDir, now, c.keyOpts...)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.refreshing = false
+		// The scan is dropped if another directory was scanned in the meantime
+		if c.scan != nil && c.scan.CertDir == certDir {
+			c.scan = scan
+		}
+	}()
+}
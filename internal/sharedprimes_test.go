@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPrime returns a random prime of the given size.
+func newTestPrime(t *testing.T, bits int) *big.Int {
+	t.Helper()
+	p, err := rand.Prime(rand.Reader, bits)
+	require.NoError(t, err)
+	return p
+}
+
+// writeTestDomainKey writes an RSA key built from the primes into the domain directory below certDir.
+func writeTestDomainKey(t *testing.T, certDir, domain, name string, p, q *big.Int) {
+	t.Helper()
+	dir := filepath.Join(certDir, domain)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	writeTestFile(t, dir, name, pemEncodeKey(t, newTestRSAKeyFromPrimes(t, p, q)))
+}
+
+func TestBatchGCD(t *testing.T) {
+	p1, p2, p3, p4, shared := newTestPrime(t, 64), newTestPrime(t, 64), newTestPrime(t, 64), newTestPrime(t, 64), newTestPrime(t, 64)
+
+	moduli := []*big.Int{
+		new(big.Int).Mul(p1, shared),
+		new(big.Int).Mul(p2, p3),
+		new(big.Int).Mul(p4, shared),
+	}
+	gcds := batchGCD(moduli)
+	require.Len(t, gcds, 3)
+	require.Equal(t, shared, gcds[0])
+	require.Equal(t, big.NewInt(1), gcds[1])
+	require.Equal(t, shared, gcds[2])
+
+	require.Equal(t, []*big.Int{big.NewInt(1)}, batchGCD(moduli[1:2]))
+	require.Nil(t, batchGCD(nil))
+}
+
+func TestNewSharedPrimeScan(t *testing.T) {
+	certDir := t.TempDir()
+	shared := newTestPrime(t, 1024)
+	writeTestDomainKey(t, certDir, "a.example.com", "privkey-1700000000.pem", shared, newTestPrime(t, 1024))
+	require.NoError(t, os.Symlink("privkey-1700000000.pem", filepath.Join(certDir, "a.example.com", "privkey.pem")))
+	writeTestDomainKey(t, certDir, "b.example.com", "privkey-1600000000.pem", newTestPrime(t, 1024), shared)
+	writeTestDomainKey(t, certDir, "b.example.com", "privkey.pem", newTestPrime(t, 1024), newTestPrime(t, 1024))
+	writeTestFile(t, filepath.Join(certDir, "b.example.com"), "cert.pem", []byte("not a key"))
+	require.NoError(t, os.Mkdir(filepath.Join(certDir, "c.example.com"), 0700))
+	writeTestFile(t, filepath.Join(certDir, "c.example.com"), "privkey.pem", pemEncodeKey(t, newTestKey(t)))
+
+	now := time.Now()
+	scan := NewSharedPrimeScan(certDir, now)
+	require.Empty(t, scan.Error)
+	require.Equal(t, 3, scan.Moduli)
+
+	a := scan.Domain("a.example.com")
+	require.True(t, a.Shared)
+	require.Equal(t, []*SharedPrimeKey{{
+		File:       filepath.Join("a.example.com", "privkey-1700000000.pem"),
+		SharedWith: []string{filepath.Join("b.example.com", "privkey-1600000000.pem")},
+	}}, a.Keys)
+	require.Equal(t, 3, a.Moduli)
+	require.Equal(t, now, a.ScannedAt)
+
+	b := scan.Domain("b.example.com")
+	require.True(t, b.Shared)
+	require.Len(t, b.Keys, 1)
+	require.Equal(t, filepath.Join("b.example.com", "privkey-1600000000.pem"), b.Keys[0].File)
+
+	c := scan.Domain("c.example.com")
+	require.False(t, c.Shared)
+	require.Empty(t, c.Keys)
+
+	// Domain names that are a prefix of another domain do not match
+	require.False(t, scan.Domain("a.example").Shared)
+}
+
+func TestNewSharedPrimeScan_ReusedKey(t *testing.T) {
+	certDir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	for _, name := range []string{"privkey-1600000000.pem", "privkey-1700000000.pem"} {
+		writeTestDomainKey(t, certDir, "example.com", name, key.Primes[0], key.Primes[1])
+	}
+
+	scan := NewSharedPrimeScan(certDir, time.Now())
+	require.Empty(t, scan.Error)
+	require.Equal(t, 1, scan.Moduli)
+	require.False(t, scan.Domain("example.com").Shared)
+}
+
+func TestNewSharedPrimeScan_InvalidDir(t *testing.T) {
+	scan := NewSharedPrimeScan("nonexistent", time.Now())
+	require.Contains(t, scan.Error, "failed to read")
+	require.Equal(t, scan.Error, scan.Domain("example.com").Error)
+}
+
+func TestSharedPrimeCache(t *testing.T) {
+	certDir := t.TempDir()
+	cache := NewSharedPrimeCache(time.Hour)
+	now := time.Now()
+
+	scan := cache.Get(certDir, now)
+	require.Same(t, scan, cache.Get(certDir, now.Add(time.Minute)))
+
+	// An expired scan is served until the background scan is done
+	require.Same(t, scan, cache.Get(certDir, now.Add(time.Hour)))
+	cache.Wait()
+	refreshed := cache.Get(certDir, now.Add(time.Hour))
+	require.NotSame(t, scan, refreshed)
+	require.Equal(t, now.Add(time.Hour), refreshed.ScannedAt)
+
+	// Another directory is scanned right away
+	other := cache.Get(t.TempDir(), now.Add(time.Hour))
+	require.NotSame(t, refreshed, other)
+	require.NotEqual(t, certDir, other.CertDir)
+}
+
+func TestSharedPrimeCache_Invalidate(t *testing.T) {
+	certDir := t.TempDir()
+	cache := NewSharedPrimeCache(time.Hour)
+	cache.Invalidate()
+	cache.Wait()
+
+	now := time.Now()
+	scan := cache.Get(certDir, now)
+	require.Empty(t, scan.Domain("a.example.com").Keys)
+
+	// Keys written after the scan are reported once the background scan triggered by Invalidate is done
+	shared := newTestPrime(t, 1024)
+	writeTestDomainKey(t, certDir, "a.example.com", "privkey.pem", shared, newTestPrime(t, 1024))
+	writeTestDomainKey(t, certDir, "b.example.com", "privkey.pem", shared, newTestPrime(t, 1024))
+	cache.Invalidate()
+	cache.Wait()
+
+	refreshed := cache.Get(certDir, now)
+	require.NotSame(t, scan, refreshed)
+	require.True(t, refreshed.Domain("a.example.com").Shared)
+}
+
+func TestSharedPrimeCache_FirstScanDoesNotBlock(t *testing.T) {
+	certDir := t.TempDir()
+	writeTestDomainKey(t, certDir, "a.example.com", "privkey.pem", newTestPrime(t, 512), newTestPrime(t, 512))
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	cache := NewSharedPrimeCache(time.Hour, func(*Key) {
+		once.Do(func() { close(started) })
+		<-release
+	})
+	now := time.Now()
+
+	scans := make(chan *SharedPrimeScan, 2)
+	go func() { scans <- cache.Get(certDir, now) }()
+	<-started
+	go func() { scans <- cache.Get(certDir, now) }()
+
+	// Neither Invalidate nor the scan of another directory wait for the running scan
+	cache.Invalidate()
+	other := cache.Get(t.TempDir(), now)
+	require.NotEqual(t, certDir, other.CertDir)
+
+	close(release)
+	first, second := <-scans, <-scans
+	require.Same(t, first, second)
+	require.Equal(t, certDir, first.CertDir)
+	require.Equal(t, 1, first.Moduli)
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"math"
+	"time"
+)
+
+// Validity status values as reported in Validity.Status.
+const (
+	ValidityStatusValid       = "valid"
+	ValidityStatusExpiring    = "expiring"
+	ValidityStatusExpired     = "expired"
+	ValidityStatusNotYetValid = "not_yet_valid"
+)
+
+// DefaultExpiringWindow is the period before NotAfter in which a certificate is considered expiring.
+const DefaultExpiringWindow = 30 * 24 * time.Hour
+
+const day = 24 * time.Hour
+
+// Validity is the evaluation of a certificate's validity period at a given point in time.
+type Validity struct {
+	Status           string  `json:"status"`            // One of valid, expiring, expired or not_yet_valid
+	SecondsRemaining int64   `json:"seconds_remaining"` // Seconds until NotAfter, negative once expired
+	DaysRemaining    int     `json:"days_remaining"`    // Whole days until NotAfter, negative once expired
+	LifetimeSeconds  int64   `json:"lifetime_seconds"`  // Total lifetime between NotBefore and NotAfter
+	LifetimeDays     int     `json:"lifetime_days"`     // Total lifetime in whole days
+	PercentElapsed   float64 `json:"percent_elapsed"`   // Share of the lifetime that has elapsed, between 0 and 100
+}
+
+// NewValidity evaluates the validity period between notBefore and notAfter at the time now.
+// A certificate is considered expiring when it expires within expiringWindow.
+func NewValidity(notBefore, notAfter, now time.Time, expiringWindow time.Duration) *Validity {
+	remaining := notAfter.Sub(now)
+	lifetime := notAfter.Sub(notBefore)
+
+	v := &Validity{
+		SecondsRemaining: int64(math.Floor(remaining.Seconds())),
+		DaysRemaining:    int(math.Floor(float64(remaining) / float64(day))),
+		LifetimeSeconds:  int64(lifetime.Seconds()),
+		LifetimeDays:     int(lifetime / day),
+	}
+
+	if lifetime > 0 {
+		elapsed := float64(now.Sub(notBefore)) / float64(lifetime) * 100
+		v.PercentElapsed = math.Round(math.Min(math.Max(elapsed, 0), 100)*100) / 100
+	}
+
+	switch {
+	case now.Before(notBefore):
+		v.Status = ValidityStatusNotYetValid
+	case now.After(notAfter):
+		v.Status = ValidityStatusExpired
+	case remaining <= expiringWindow:
+		v.Status = ValidityStatusExpiring
+	default:
+		v.Status = ValidityStatusValid
+	}
+
+	return v
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewValidity_Status(t *testing.T) {
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(90 * day)
+
+	testCases := []struct {
+		name     string
+		now      time.Time
+		expected string
+	}{
+		{"NotYetValid", notBefore.Add(-time.Hour), ValidityStatusNotYetValid},
+		{"Valid", notBefore.Add(10 * day), ValidityStatusValid},
+		{"Expiring", notAfter.Add(-10 * day), ValidityStatusExpiring},
+		{"ExpiringBoundary", notAfter.Add(-30 * day), ValidityStatusExpiring},
+		{"Expired", notAfter.Add(time.Second), ValidityStatusExpired},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NewValidity(notBefore, notAfter, tc.now, DefaultExpiringWindow)
+			require.Equal(t, tc.expected, v.Status)
+		})
+	}
+}
+
+func TestNewValidity_Remaining(t *testing.T) {
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(90 * day)
+
+	v := NewValidity(notBefore, notAfter, notBefore.Add(45*day), DefaultExpiringWindow)
+	require.Equal(t, int64(45*24*60*60), v.SecondsRemaining)
+	require.Equal(t, 45, v.DaysRemaining)
+	require.Equal(t, int64(90*24*60*60), v.LifetimeSeconds)
+	require.Equal(t, 90, v.LifetimeDays)
+	require.InDelta(t, 50.0, v.PercentElapsed, 0.001)
+
+	v = NewValidity(notBefore, notAfter, notAfter.Add(12*time.Hour), DefaultExpiringWindow)
+	require.Equal(t, -1, v.DaysRemaining)
+	require.Equal(t, int64(-12*60*60), v.SecondsRemaining)
+	require.InDelta(t, 100.0, v.PercentElapsed, 0.001)
+
+	v = NewValidity(notBefore, notAfter, notBefore.Add(-day), DefaultExpiringWindow)
+	require.InDelta(t, 0.0, v.PercentElapsed, 0.001)
+}
+
+func TestNewValidity_CustomWindow(t *testing.T) {
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(90 * day)
+	now := notAfter.Add(-10 * day)
+
+	require.Equal(t, ValidityStatusValid, NewValidity(notBefore, notAfter, now, 7*day).Status)
+	require.Equal(t, ValidityStatusExpiring, NewValidity(notBefore, notAfter, now, 14*day).Status)
+}
+
+func TestCertificate_Evaluate(t *testing.T) {
+	cert := &Certificate{File: "nonexistent.crt"}
+	cert.Evaluate(time.Now(), DefaultExpiringWindow)
+	require.Nil(t, cert.Validity)
+
+	now := time.Now()
+	cert = &Certificate{NotBefore: now.Add(-day), NotAfter: now.Add(5 * day)}
+	cert.Evaluate(now, DefaultExpiringWindow)
+	require.NotNil(t, cert.Validity)
+	require.Equal(t, ValidityStatusExpiring, cert.Validity.Status)
+}
+
+func TestChain_Evaluate(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestLeaf(t, root, "example.com")
+	file := writeTestFile(t, t.TempDir(), "fullchain.pem", pemEncodeCerts(leaf, root))
+
+	chain := NewChain(file)
+	chain.Evaluate(time.Now(), DefaultExpiringWindow)
+	for _, entry := range chain.Certificates {
+		require.NotNil(t, entry.Validity)
+		require.Equal(t, ValidityStatusValid, entry.Validity.Status)
+	}
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// TrustSourceSystem is the TrustStore source of the operating system's root certificates.
+const TrustSourceSystem = "system"
+
+// Verification error reasons as reported in VerificationError.Reason.
+const (
+	VerificationReasonExpired                = "expired"
+	VerificationReasonNotAuthorizedToSign    = "not_authorized_to_sign"
+	VerificationReasonCANotAuthorizedForName = "ca_not_authorized_for_this_name"
+	VerificationReasonTooManyIntermediates   = "too_many_intermediates"
+	VerificationReasonIncompatibleUsage      = "incompatible_usage"
+	VerificationReasonNameMismatch           = "name_mismatch"
+	VerificationReasonNameConstraintsNoSANs  = "name_constraints_without_sans"
+	VerificationReasonUnconstrainedName      = "unconstrained_name"
+	VerificationReasonTooManyConstraints     = "too_many_constraints"
+	VerificationReasonCANotAuthorizedForEKU  = "ca_not_authorized_for_ext_key_usage"
+	VerificationReasonUnknownAuthority       = "unknown_authority"
+	VerificationReasonSystemRootsUnavailable = "system_roots_unavailable"
+	VerificationReasonOther                  = "other"
+)
+
+// invalidReasons maps the x509.InvalidReason values to their verification error reasons.
+var invalidReasons = map[x509.InvalidReason]string{
+	x509.NotAuthorizedToSign:           VerificationReasonNotAuthorizedToSign,
+	x509.Expired:                       VerificationReasonExpired,
+	x509.CANotAuthorizedForThisName:    VerificationReasonCANotAuthorizedForName,
+	x509.TooManyIntermediates:          VerificationReasonTooManyIntermediates,
+	x509.IncompatibleUsage:             VerificationReasonIncompatibleUsage,
+	x509.NameMismatch:                  VerificationReasonNameMismatch,
+	x509.NameConstraintsWithoutSANs:    VerificationReasonNameConstraintsNoSANs,
+	x509.UnconstrainedName:             VerificationReasonUnconstrainedName,
+	x509.TooManyConstraints:            VerificationReasonTooManyConstraints,
+	x509.CANotAuthorizedForExtKeyUsage: VerificationReasonCANotAuthorizedForEKU,
+}
+
+// TrustStore is a set of root certificates used to verify certificate chains.
+type TrustStore struct {
+	Source string // TrustSourceSystem or the path of the CA bundle
+
+	pool *x509.CertPool // nil selects the system roots
+}
+
+// SystemTrustStore returns a TrustStore backed by the operating system's root certificates.
+func SystemTrustStore() *TrustStore {
+	return &TrustStore{Source: TrustSourceSystem}
+}
+
+// LoadTrustStore returns a TrustStore containing the root certificates of the PEM encoded CA bundle at path.
+func LoadTrustStore(path string) (*TrustStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return &TrustStore{Source: path, pool: pool}, nil
+}
+
+// Verification is the result of verifying the leaf certificate against the chain and a TrustStore.
+type Verification struct {
+	Valid  bool                   `json:"valid"`            // Whether a valid path to a trusted root was found
+	Roots  string                 `json:"roots"`            // Source of the root certificates, "system" or a CA bundle path
+	Path   []*VerifiedCertificate `json:"path,omitempty"`   // Verified path from the leaf to the root
+	Errors []*VerificationError   `json:"errors,omitempty"` // Reasons why verification failed
+	Error  string                 `json:"error,omitempty"`  // Error represents any error that prevented verification.
+}
+
+// VerifiedCertificate identifies a certificate of the verified path.
+type VerifiedCertificate struct {
+	Subject string `json:"subject"` // Certificate subject DN
+	SHA256  string `json:"sha256"`  // SHA-256 fingerprint of the certificate in colon separated hex
+}
+
+// VerificationError is a single reason why the chain could not be verified.
+type VerificationError struct {
+	Reason  string `json:"reason"`  // Machine readable reason, e.g. expired or unknown_authority
+	Message string `json:"message"` // Error message as returned by the verifier
+}
+
+// NewVerification verifies the certificate for server authentication at the time now,
+// using the certificates of chain as intermediates and the roots of store.
+func NewVerification(cert *Certificate, chain *Chain, store *TrustStore, now time.Time) *Verification {
+	v := &Verification{
+		Roots: store.Source,
+	}
+
+	leaf := cert.X509()
+	if leaf == nil {
+		v.Error = "certificate could not be analyzed"
+		return v
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, entry := range chain.Certificates {
+		if entry.X509() != nil {
+			intermediates.AddCert(entry.X509())
+		}
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         store.pool,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		v.Errors = append(v.Errors, newVerificationError(err))
+		return v
+	}
+
+	v.Valid = true
+	for _, c := range chains[0] {
+		v.Path = append(v.Path, &VerifiedCertificate{
+			Subject: c.Subject.String(),
+			SHA256:  newFingerprints(c).SHA256,
+		})
+	}
+
+	return v
+}
+
+// newVerificationError classifies the error returned by x509.Certificate.Verify.
+func newVerificationError(err error) *VerificationError {
+	ve := &VerificationError{
+		Reason:  VerificationReasonOther,
+		Message: err.Error(),
+	}
+
+	var invalidErr x509.CertificateInvalidError
+	var unknownErr x509.UnknownAuthorityError
+	var systemErr x509.SystemRootsError
+
+	switch {
+	case errors.As(err, &invalidErr):
+		if reason, ok := invalidReasons[invalidErr.Reason]; ok {
+			ve.Reason = reason
+		}
+	case errors.As(err, &unknownErr):
+		ve.Reason = VerificationReasonUnknownAuthority
+	case errors.As(err, &systemErr):
+		ve.Reason = VerificationReasonSystemRootsUnavailable
+	}
+
+	return ve
+}
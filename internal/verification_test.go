@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTrustStore writes the given roots to a CA bundle and loads it.
+func newTestTrustStore(t *testing.T, roots ...*testCert) *TrustStore {
+	t.Helper()
+	store, err := LoadTrustStore(writeTestFile(t, t.TempDir(), "ca-bundle.pem", pemEncodeCerts(roots...)))
+	require.NoError(t, err)
+	return store
+}
+
+func TestNewVerification_Valid(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+	dir := t.TempDir()
+
+	store := newTestTrustStore(t, root)
+	v := NewVerification(
+		NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf))),
+		NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(intermediate))),
+		store,
+		time.Now(),
+	)
+
+	require.True(t, v.Valid)
+	require.Empty(t, v.Errors)
+	require.Equal(t, store.Source, v.Roots)
+	require.Len(t, v.Path, 3)
+	require.Equal(t, "CN=example.com", v.Path[0].Subject)
+	require.Equal(t, "CN=Test Intermediate", v.Path[1].Subject)
+	require.Equal(t, "CN=Test Root", v.Path[2].Subject)
+	require.Equal(t, newFingerprints(root.cert).SHA256, v.Path[2].SHA256)
+}
+
+func TestNewVerification_MissingIntermediate(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	intermediate := newTestCA(t, "Test Intermediate", root)
+	leaf := newTestLeaf(t, intermediate, "example.com")
+	dir := t.TempDir()
+
+	v := NewVerification(
+		NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf))),
+		NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(root))),
+		newTestTrustStore(t, root),
+		time.Now(),
+	)
+
+	require.False(t, v.Valid)
+	require.Empty(t, v.Path)
+	require.Len(t, v.Errors, 1)
+	require.Equal(t, VerificationReasonUnknownAuthority, v.Errors[0].Reason)
+}
+
+func TestNewVerification_Expired(t *testing.T) {
+	root := newTestCA(t, "Test Root", nil)
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "example.com"},
+		DNSNames:    []string{"example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		NotBefore:   time.Now().Add(-48 * time.Hour),
+		NotAfter:    time.Now().Add(-24 * time.Hour),
+	}, nil, root)
+	dir := t.TempDir()
+
+	v := NewVerification(
+		NewCertificate(writeTestFile(t, dir, "cert.pem", pemEncodeCerts(leaf))),
+		NewChain(writeTestFile(t, dir, "chain.pem", pemEncodeCerts(root))),
+		newTestTrustStore(t, root),
+		time.Now(),
+	)
+
+	require.False(t, v.Valid)
+	require.Len(t, v.Errors, 1)
+	require.Equal(t, VerificationReasonExpired, v.Errors[0].Reason)
+}
+
+func TestNewVerification_MissingCertificate(t *testing.T) {
+	v := NewVerification(NewCertificate("nonexistent.crt"), NewChain("nonexistent.pem"), SystemTrustStore(), time.Now())
+	require.False(t, v.Valid)
+	require.Equal(t, TrustSourceSystem, v.Roots)
+	require.Equal(t, "certificate could not be analyzed", v.Error)
+}
+
+func TestLoadTrustStore_Errors(t *testing.T) {
+	_, err := LoadTrustStore("nonexistent.pem")
+	require.ErrorContains(t, err, "failed to read")
+
+	_, err = LoadTrustStore(writeTestFile(t, t.TempDir(), "ca-bundle.pem", []byte("invalid content")))
+	require.ErrorContains(t, err, "no certificates found")
+}
+
+func TestNewVerificationError(t *testing.T) {
+	require.Equal(t, VerificationReasonNotAuthorizedToSign,
+		newVerificationError(x509.CertificateInvalidError{Reason: x509.NotAuthorizedToSign}).Reason)
+	require.Equal(t, VerificationReasonSystemRootsUnavailable, newVerificationError(x509.SystemRootsError{}).Reason)
+	require.Equal(t, VerificationReasonOther, newVerificationError(errors.New("boom")).Reason)
+}
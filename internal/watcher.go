@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hashicorp/go-hclog"
+)
+
+// DefaultWatchDelay is the time the watcher waits after the last change in a domain directory before reporting it,
+// so a renewal writing several files is reported once.
+const DefaultWatchDelay = 2 * time.Second
+
+// watchedFiles are the plain file names whose changes are reported, in addition to the timestamped files.
+var watchedFiles = map[string]bool{
+	"privkey.pem":   true,
+	"cert.pem":      true,
+	"chain.pem":     true,
+	"fullchain.pem": true,
+	"cert.csr":      true,
+	"ocsp.der":      true,
+	"config":        true,
+}
+
+// Watcher watches dehydrated's certificate directory and reports domain directories whose certificate files
+// changed, e.g. because dehydrated stored a renewed certificate and swapped the symlinks.
+type Watcher struct {
+	logger   hclog.Logger
+	delay    time.Duration
+	onChange func(domainDir string)
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+	wg       sync.WaitGroup
+	closed   sync.Once
+
+	callbacks sync.WaitGroup // Running onChange calls, registered while holding mu before done is closed
+
+	mu       sync.Mutex
+	certDirs map[string]bool        // Watched certificate directories
+	timers   map[string]*time.Timer // Pending reports by domain directory
+}
+
+// NewWatcher creates a watcher that calls onChange in the background once the files of a domain directory
+// stopped changing for delay. Directories are added with Watch.
+func NewWatcher(logger hclog.Logger, delay time.Duration, onChange func(domainDir string)) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	w := &Watcher{
+		logger:   logger,
+		delay:    delay,
+		onChange: onChange,
+		watcher:  fw,
+		done:     make(chan struct{}),
+		certDirs: map[string]bool{},
+		timers:   map[string]*time.Timer{},
+	}
+	w.wg.Add(1)
+	go w.run()
+
+	return w, nil
+}
+
+// Watch adds the certificate directory and all its domain directories. Watching a directory again has no effect.
+func (w *Watcher) Watch(certDir string) error {
+	certDir = filepath.Clean(certDir)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.certDirs[certDir] {
+		return nil
+	}
+	if err := w.watcher.Add(certDir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", certDir, err)
+	}
+	w.certDirs[certDir] = true
+
+	entries, err := os.ReadDir(certDir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", certDir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			w.addDomainDir(filepath.Join(certDir, entry.Name()))
+		}
+	}
+	w.logger.Info("Watching certificate directory", "certDir", certDir)
+
+	return nil
+}
+
+// Close stops watching, discards pending reports and waits for running onChange calls to return.
+// Closing a closed watcher has no effect.
+func (w *Watcher) Close() error {
+	var err error
+	w.closed.Do(func() {
+		w.mu.Lock()
+		close(w.done)
+		w.mu.Unlock()
+		err = w.watcher.Close()
+	})
+	w.wg.Wait()
+
+	w.mu.Lock()
+	for domainDir, timer := range w.timers {
+		timer.Stop()
+		delete(w.timers, domainDir)
+	}
+	w.mu.Unlock()
+	w.callbacks.Wait()
+
+	return err
+}
+
+// run handles the events until the watcher is closed.
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("Watcher error", "error", err)
+		}
+	}
+}
+
+// handle watches new domain directories and schedules a report for changed certificate files.
+func (w *Watcher) handle(event fsnotify.Event) {
+	dir, name := filepath.Split(event.Name)
+	dir = filepath.Clean(dir)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.certDirs[dir] {
+		// A new domain directory, e.g. for a domain added to domains.txt
+		if event.Has(fsnotify.Create) {
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				w.addDomainDir(event.Name)
+				w.schedule(event.Name)
+			}
+		}
+		return
+	}
+
+	if !watchedFiles[name] && !historyFilePattern.MatchString(name) {
+		return
+	}
+	w.logger.Info("Certificate file changed", "file", event.Name, "op", event.Op.String())
+	w.schedule(dir)
+}
+
+// addDomainDir watches a domain directory. The caller must hold the lock.
+func (w *Watcher) addDomainDir(domainDir string) {
+	if err := w.watcher.Add(domainDir); err != nil {
+		w.logger.Warn("Failed to watch domain directory", "domainDir", domainDir, "error", err)
+	}
+}
+
+// schedule reports the domain directory after the delay, postponing a pending report. The caller must hold the lock.
+func (w *Watcher) schedule(domainDir string) {
+	if timer, ok := w.timers[domainDir]; ok {
+		timer.Reset(w.delay)
+		return
+	}
+	w.timers[domainDir] = time.AfterFunc(w.delay, func() {
+		w.mu.Lock()
+		delete(w.timers, domainDir)
+		select {
+		case <-w.done:
+			w.mu.Unlock()
+			return
+		default:
+		}
+		w.callbacks.Add(1)
+		w.mu.Unlock()
+		defer w.callbacks.Done()
+
+		w.logger.Debug("Refreshing domain directory", "domainDir", domainDir)
+		w.onChange(domainDir)
+	})
+}
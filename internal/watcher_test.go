@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWatcher starts a watcher with a short delay that sends the changed domain directories to the returned channel.
+func newTestWatcher(t *testing.T) (*Watcher, chan string) {
+	t.Helper()
+	changes := make(chan string, 10)
+	w, err := NewWatcher(hclog.NewNullLogger(), 50*time.Millisecond, func(domainDir string) {
+		changes <- domainDir
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+	return w, changes
+}
+
+// requireChange waits for a reported domain directory.
+func requireChange(t *testing.T, changes chan string) string {
+	t.Helper()
+	select {
+	case domainDir := <-changes:
+		return domainDir
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no change reported")
+		return ""
+	}
+}
+
+func TestWatcher_Renewal(t *testing.T) {
+	certDir := t.TempDir()
+	domainDir := filepath.Join(certDir, "example.com")
+	require.NoError(t, os.Mkdir(domainDir, 0700))
+	w, changes := newTestWatcher(t)
+	require.NoError(t, w.Watch(certDir))
+	require.NoError(t, w.Watch(certDir))
+
+	// A renewal writes several files and swaps the symlinks, which is reported once
+	writeTestFile(t, domainDir, "cert-1700000000.pem", []byte("cert"))
+	writeTestFile(t, domainDir, "privkey-1700000000.pem", []byte("key"))
+	require.NoError(t, os.Symlink("cert-1700000000.pem", filepath.Join(domainDir, "cert.pem")))
+	require.Equal(t, domainDir, requireChange(t, changes))
+
+	select {
+	case domainDir := <-changes:
+		require.FailNow(t, "unexpected change", domainDir)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Unrelated files are ignored
+	writeTestFile(t, domainDir, "notes.txt", []byte("notes"))
+	select {
+	case domainDir := <-changes:
+		require.FailNow(t, "unexpected change", domainDir)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcher_NewDomainDirectory(t *testing.T) {
+	certDir := t.TempDir()
+	w, changes := newTestWatcher(t)
+	require.NoError(t, w.Watch(certDir))
+
+	domainDir := filepath.Join(certDir, "new.example.com")
+	require.NoError(t, os.Mkdir(domainDir, 0700))
+	require.Equal(t, domainDir, requireChange(t, changes))
+
+	writeTestFile(t, domainDir, "cert.pem", []byte("cert"))
+	require.Equal(t, domainDir, requireChange(t, changes))
+}
+
+func TestWatcher_Close(t *testing.T) {
+	certDir := t.TempDir()
+	domainDir := filepath.Join(certDir, "example.com")
+	require.NoError(t, os.Mkdir(domainDir, 0700))
+	w, changes := newTestWatcher(t)
+	require.NoError(t, w.Watch(certDir))
+
+	writeTestFile(t, domainDir, "cert.pem", []byte("cert"))
+	require.NoError(t, w.Close())
+	select {
+	case domainDir := <-changes:
+		require.FailNow(t, "unexpected change after close", domainDir)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcher_Close_WaitsForCallback(t *testing.T) {
+	certDir := t.TempDir()
+	domainDir := filepath.Join(certDir, "example.com")
+	require.NoError(t, os.Mkdir(domainDir, 0700))
+	started, release := make(chan struct{}), make(chan struct{})
+	w, err := NewWatcher(hclog.NewNullLogger(), 50*time.Millisecond, func(string) {
+		close(started)
+		<-release
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.Watch(certDir))
+
+	writeTestFile(t, domainDir, "cert.pem", []byte("cert"))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no change reported")
+	}
+
+	closed := make(chan error)
+	go func() { closed <- w.Close() }()
+	select {
+	case <-closed:
+		require.FailNow(t, "Close returned while the callback is running")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err = <-closed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Close did not return after the callback")
+	}
+}
+
+func TestWatcher_InvalidDir(t *testing.T) {
+	w, _ := newTestWatcher(t)
+	require.ErrorContains(t, w.Watch(filepath.Join(t.TempDir(), "nonexistent")), "failed to watch")
+}
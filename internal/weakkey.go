@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Severities of weak key findings as reported in KeyFinding.Severity.
+const (
+	SeverityCritical = "critical" // The private key can be computed from the public key
+	SeverityHigh     = "high"     // The private key can be computed with considerable but feasible effort
+)
+
+// Names of the weak key checks as reported in KeyFinding.Check.
+const (
+	WeakKeyCheckROCA        = "roca"
+	WeakKeyCheckFermat      = "fermat"
+	WeakKeyCheckSmallFactor = "small_factor"
+	WeakKeyCheckBlocklist   = "blocklist"
+)
+
+const (
+	// fermatRounds is the number of Fermat factorization steps, enough to factor moduli of primes
+	// generated by implementations that search for both primes starting from related values.
+	fermatRounds = 100
+
+	// smallFactorBound is the exclusive upper bound of the primes used for trial division.
+	smallFactorBound = 1 << 16
+
+	// rocaGenerator is the generator of the multiplicative subgroup the primes of ROCA vulnerable keys lie in.
+	rocaGenerator = 65537
+)
+
+// rocaPrimes are the small primes of the ROCA fingerprint test (CVE-2017-15361).
+var rocaPrimes = []int{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109,
+	113, 127, 131, 137, 139, 149, 151, 157, 163, 167,
+}
+
+// rocaSubgroups holds, for every ROCA prime, the residues generated by rocaGenerator modulo that prime.
+var rocaSubgroups = func() map[int]map[int]bool {
+	subgroups := make(map[int]map[int]bool, len(rocaPrimes))
+	for _, p := range rocaPrimes {
+		subgroup := map[int]bool{}
+		for r := 1; !subgroup[r]; r = r * (rocaGenerator % p) % p {
+			subgroup[r] = true
+		}
+		subgroups[p] = subgroup
+	}
+	return subgroups
+}()
+
+// oidRSAEncryption identifies RSA keys in PKCS#8 structures.
+var oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+
+// smallPrimes are the primes below smallFactorBound, smallPrimesProduct is their product.
+var smallPrimes, smallPrimesProduct = func() ([]int64, *big.Int) {
+	composite := make([]bool, smallFactorBound)
+	primes := []int64{}
+	product := big.NewInt(1)
+	for i := 2; i < smallFactorBound; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, int64(i))
+		product.Mul(product, big.NewInt(int64(i)))
+		for j := i * i; j < smallFactorBound; j += i {
+			composite[j] = true
+		}
+	}
+	return primes, product
+}()
+
+// KeyFinding is a weakness found by the weak key checks.
+type KeyFinding struct {
+	Check    string `json:"check"`    // Name of the check: roca, fermat, small_factor or blocklist
+	Severity string `json:"severity"` // Severity of the weakness: critical or high
+	Message  string `json:"message"`  // Description of the weakness
+}
+
+// KeyBlocklist is a set of SHA-256 SPKI fingerprints of known compromised keys.
+type KeyBlocklist struct {
+	File   string              // Path the blocklist was loaded from
+	hashes map[string]struct{} // Fingerprints in colon separated hex
+}
+
+// LoadKeyBlocklist reads a blocklist with one SHA-256 SPKI fingerprint in hex per line.
+// Colons are optional and case is ignored; empty lines and lines starting with # are skipped.
+func LoadKeyBlocklist(file string) (*KeyBlocklist, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	bl := &KeyBlocklist{File: file, hashes: map[string]struct{}{}}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, decodeErr := hex.DecodeString(strings.ReplaceAll(line, ":", ""))
+		if decodeErr != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 fingerprint in %s line %d", file, n)
+		}
+		bl.hashes[colonHex(sum)] = struct{}{}
+	}
+
+	return bl, scanner.Err()
+}
+
+// Contains reports whether the fingerprint in colon separated hex is on the blocklist.
+func (bl *KeyBlocklist) Contains(spkiSHA256 string) bool {
+	if bl == nil {
+		return false
+	}
+	_, ok := bl.hashes[spkiSHA256]
+	return ok
+}
+
+// checkWeakKey runs the weak key checks applicable to the public key and returns the findings.
+func checkWeakKey(pub crypto.PublicKey, spkiSHA256 string, blocklist *KeyBlocklist) []*KeyFinding {
+	var findings []*KeyFinding
+
+	if blocklist.Contains(spkiSHA256) {
+		findings = append(findings, &KeyFinding{
+			Check:    WeakKeyCheckBlocklist,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("SPKI fingerprint is listed in %s", blocklist.File),
+		})
+	}
+
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok || rsaKey.N == nil {
+		return findings
+	}
+	if factor := smallFactor(rsaKey.N); factor != 0 {
+		findings = append(findings, &KeyFinding{
+			Check:    WeakKeyCheckSmallFactor,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("modulus is divisible by %d", factor),
+		})
+	}
+	if p := fermatFactor(rsaKey.N); p != nil {
+		findings = append(findings, &KeyFinding{
+			Check:    WeakKeyCheckFermat,
+			Severity: SeverityCritical,
+			Message:  "modulus was factored with Fermat's method, the primes are too close",
+		})
+	}
+	if hasROCAFingerprint(rsaKey.N) {
+		findings = append(findings, &KeyFinding{
+			Check:    WeakKeyCheckROCA,
+			Severity: SeverityHigh,
+			Message:  "modulus has the ROCA fingerprint (CVE-2017-15361)",
+		})
+	}
+
+	return findings
+}
+
+// smallFactor returns the smallest prime factor of n below smallFactorBound, or 0 if there is none.
+func smallFactor(n *big.Int) int64 {
+	gcd := new(big.Int).GCD(nil, nil, n, smallPrimesProduct)
+	if gcd.Cmp(big.NewInt(1)) == 0 {
+		return 0
+	}
+	rem := new(big.Int)
+	for _, p := range smallPrimes {
+		if rem.Mod(gcd, big.NewInt(p)).Sign() == 0 {
+			return p
+		}
+	}
+	return 0
+}
+
+// fermatFactor returns a factor of n if n is the product of two primes close enough to be found
+// within fermatRounds steps of Fermat's factorization method, or nil otherwise.
+func fermatFactor(n *big.Int) *big.Int {
+	if n.Sign() <= 0 || n.Bit(0) == 0 {
+		return nil
+	}
+
+	// Search a with a² - n = b², then n = (a - b)(a + b)
+	a := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(a, a).Cmp(n) < 0 {
+		a.Add(a, big.NewInt(1))
+	}
+	b2 := new(big.Int).Mul(a, a)
+	b2.Sub(b2, n)
+	b := new(big.Int)
+	step := new(big.Int)
+	for range fermatRounds {
+		b.Sqrt(b2)
+		if new(big.Int).Mul(b, b).Cmp(b2) == 0 {
+			p := new(big.Int).Sub(a, b)
+			if p.Cmp(big.NewInt(1)) > 0 {
+				return p
+			}
+			return nil
+		}
+		// (a + 1)² - n = a² - n + 2a + 1
+		step.Lsh(a, 1)
+		step.Add(step, big.NewInt(1))
+		b2.Add(b2, step)
+		a.Add(a, big.NewInt(1))
+	}
+	return nil
+}
+
+// hasROCAFingerprint reports whether n is congruent to a power of 65537 modulo every ROCA prime,
+// which holds for moduli generated by the vulnerable Infineon RSALib but practically never for other keys.
+func hasROCAFingerprint(n *big.Int) bool {
+	rem := new(big.Int)
+	for _, p := range rocaPrimes {
+		r := int(rem.Mod(n, big.NewInt(int64(p))).Int64())
+		if !rocaSubgroups[p][r] {
+			return false
+		}
+	}
+	return true
+}
+
+// pkcs1Modulus holds the leading fields of a PKCS#1 RSAPrivateKey.
+type pkcs1Modulus struct {
+	Version int
+	N       *big.Int
+	E       int
+}
+
+// pkcs8Envelope holds the leading fields of a PKCS#8 PrivateKeyInfo.
+type pkcs8Envelope struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// parseWeakRSAPrivateKey returns the public half of a PKCS#1 or PKCS#8 RSA private key that crypto/rsa refuses to load,
+// if its modulus fails a weak key check. Go rejects for example keys with too close primes, which must still be reported.
+func parseWeakRSAPrivateKey(der []byte) *rsa.PrivateKey {
+	var envelope pkcs8Envelope
+	if _, err := asn1.Unmarshal(der, &envelope); err == nil && envelope.Algo.Algorithm.Equal(oidRSAEncryption) {
+		der = envelope.PrivateKey
+	}
+
+	var key pkcs1Modulus
+	if _, err := asn1.Unmarshal(der, &key); err != nil || key.N == nil || key.N.Sign() <= 0 {
+		return nil
+	}
+	pub := rsa.PublicKey{N: key.N, E: key.E}
+	if len(checkWeakKey(&pub, "", nil)) == 0 {
+		return nil
+	}
+	return &rsa.PrivateKey{PublicKey: pub}
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRSAKeyFromPrimes builds an RSA private key from the given primes.
+func newTestRSAKeyFromPrimes(t *testing.T, p, q *big.Int) *rsa.PrivateKey {
+	t.Helper()
+	one := big.NewInt(1)
+	pm1 := new(big.Int).Sub(p, one)
+	qm1 := new(big.Int).Sub(q, one)
+	lambda := new(big.Int).Mul(pm1, qm1)
+	lambda.Div(lambda, new(big.Int).GCD(nil, nil, pm1, qm1))
+
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: new(big.Int).Mul(p, q), E: 65537},
+		D:         new(big.Int).ModInverse(big.NewInt(65537), lambda),
+		Primes:    []*big.Int{p, q},
+	}
+	require.NotNil(t, key.D)
+	return key
+}
+
+// pemEncodePKCS1Key encodes an RSA private key without the validation x509.MarshalPKCS8PrivateKey performs.
+func pemEncodePKCS1Key(t *testing.T, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(struct {
+		Version               int
+		N                     *big.Int
+		E                     int
+		D, P, Q, Dp, Dq, Qinv *big.Int
+	}{0, key.N, key.E, key.D, key.Primes[0], key.Primes[1], big.NewInt(1), big.NewInt(1), big.NewInt(1)})
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der})
+}
+
+// newTestClosePrimes returns two distinct primes that are next to each other.
+func newTestClosePrimes(t *testing.T, bits int) (p, q *big.Int) {
+	t.Helper()
+	p, err := rand.Prime(rand.Reader, bits)
+	require.NoError(t, err)
+	q = new(big.Int).Add(p, big.NewInt(2))
+	for !q.ProbablyPrime(20) {
+		q.Add(q, big.NewInt(2))
+	}
+	return p, q
+}
+
+func TestCheckWeakKey_StrongKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	require.Empty(t, checkWeakKey(key.Public(), "", nil))
+	require.Empty(t, checkWeakKey(newTestKey(t).Public(), "", nil))
+}
+
+func TestCheckWeakKey_Fermat(t *testing.T) {
+	p, q := newTestClosePrimes(t, 1024)
+	key := newTestRSAKeyFromPrimes(t, p, q)
+
+	findings := checkWeakKey(key.Public(), "", nil)
+	require.Len(t, findings, 1)
+	require.Equal(t, WeakKeyCheckFermat, findings[0].Check)
+	require.Equal(t, SeverityCritical, findings[0].Severity)
+
+	factor := fermatFactor(key.N)
+	require.NotNil(t, factor)
+	require.True(t, factor.Cmp(p) == 0 || factor.Cmp(q) == 0)
+}
+
+func TestCheckWeakKey_SmallFactor(t *testing.T) {
+	p, err := rand.Prime(rand.Reader, 1024)
+	require.NoError(t, err)
+	n := new(big.Int).Mul(p, big.NewInt(65521))
+
+	findings := checkWeakKey(&rsa.PublicKey{N: n, E: 65537}, "", nil)
+	require.NotEmpty(t, findings)
+	require.Equal(t, WeakKeyCheckSmallFactor, findings[0].Check)
+	require.Equal(t, "modulus is divisible by 65521", findings[0].Message)
+
+	require.Equal(t, int64(3), smallFactor(big.NewInt(3*65521)))
+	require.Zero(t, smallFactor(p))
+}
+
+func TestHasROCAFingerprint(t *testing.T) {
+	// A modulus congruent to 1 = 65537^0 modulo every ROCA prime carries the fingerprint
+	m := big.NewInt(1)
+	for _, p := range rocaPrimes {
+		m.Mul(m, big.NewInt(int64(p)))
+	}
+	k, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 1800))
+	require.NoError(t, err)
+	n := new(big.Int).Mul(m, k)
+	n.Add(n, big.NewInt(1))
+	require.True(t, hasROCAFingerprint(n))
+
+	findings := checkWeakKey(&rsa.PublicKey{N: n, E: 65537}, "", nil)
+	require.Contains(t, findings, &KeyFinding{
+		Check:    WeakKeyCheckROCA,
+		Severity: SeverityHigh,
+		Message:  "modulus has the ROCA fingerprint (CVE-2017-15361)",
+	})
+
+	// 2 is not a power of 65537 modulo 11, since 65537 ≡ 10 (mod 11) has order 2
+	require.False(t, hasROCAFingerprint(new(big.Int).Add(n, big.NewInt(1))))
+}
+
+func TestRocaSubgroups(t *testing.T) {
+	// 65537 ≡ 2 (mod 3) generates the whole group, 65537 ≡ 10 (mod 11) a subgroup of order 2
+	require.Equal(t, map[int]bool{1: true, 2: true}, rocaSubgroups[3])
+	require.Equal(t, map[int]bool{1: true, 10: true}, rocaSubgroups[11])
+}
+
+func TestLoadKeyBlocklist(t *testing.T) {
+	key := newTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	sum := sha256.Sum256(der)
+	other := sha256.Sum256([]byte("other"))
+
+	file := writeTestFile(t, t.TempDir(), "blocklist", []byte(
+		"# compromised keys\n\n"+hex.EncodeToString(sum[:])+"\n"+strings.ToLower(colonHex(other[:]))+"\n"))
+
+	bl, err := LoadKeyBlocklist(file)
+	require.NoError(t, err)
+	require.True(t, bl.Contains(colonHex(sum[:])))
+	require.True(t, bl.Contains(colonHex(other[:])))
+	require.False(t, bl.Contains(colonHex(make([]byte, 32))))
+
+	findings := checkWeakKey(key.Public(), colonHex(sum[:]), bl)
+	require.Len(t, findings, 1)
+	require.Equal(t, WeakKeyCheckBlocklist, findings[0].Check)
+	require.Equal(t, SeverityCritical, findings[0].Severity)
+
+	var nilList *KeyBlocklist
+	require.False(t, nilList.Contains(colonHex(sum[:])))
+}
+
+func TestLoadKeyBlocklist_Invalid(t *testing.T) {
+	_, err := LoadKeyBlocklist("nonexistent")
+	require.ErrorContains(t, err, "failed to read")
+
+	file := writeTestFile(t, t.TempDir(), "blocklist", []byte("# comment\nDE:AD:BE:EF\n"))
+	_, err = LoadKeyBlocklist(file)
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestNewKey_Findings(t *testing.T) {
+	p, q := newTestClosePrimes(t, 1024)
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "privkey.pem", pemEncodePKCS1Key(t, newTestRSAKeyFromPrimes(t, p, q)))
+
+	key := NewKey(file)
+	require.Empty(t, key.Error)
+	require.Equal(t, KeyTypeRSA, key.Type)
+	require.Equal(t, 2048, key.Size)
+	require.Len(t, key.Findings, 1)
+	require.Equal(t, WeakKeyCheckFermat, key.Findings[0].Check)
+
+	blocklist := writeTestFile(t, dir, "blocklist", []byte(key.SPKISHA256+"\n"))
+	bl, err := LoadKeyBlocklist(blocklist)
+	require.NoError(t, err)
+	key = NewKey(file, WithBlocklist(bl))
+	require.Len(t, key.Findings, 2)
+	require.Equal(t, WeakKeyCheckBlocklist, key.Findings[0].Check)
+}
+
+func TestParseWeakRSAPrivateKey(t *testing.T) {
+	strong, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	require.Nil(t, parseWeakRSAPrivateKey(x509.MarshalPKCS1PrivateKey(strong)))
+	require.Nil(t, parseWeakRSAPrivateKey([]byte("invalid")))
+
+	p, q := newTestClosePrimes(t, 1024)
+	block, _ := pem.Decode(pemEncodePKCS1Key(t, newTestRSAKeyFromPrimes(t, p, q)))
+	weak := parseWeakRSAPrivateKey(block.Bytes)
+	require.NotNil(t, weak)
+	require.Equal(t, new(big.Int).Mul(p, q), weak.N)
+
+	// PKCS#8 envelope around the PKCS#1 key
+	der, err := asn1.Marshal(pkcs8Envelope{
+		Algo:       pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+		PrivateKey: block.Bytes,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, parseWeakRSAPrivateKey(der))
+}
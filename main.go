@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/schumann-it/dehydrated-api-metadata-plugin-openssl/internal"
 
@@ -25,15 +28,87 @@ var (
 type OpensslPlugin struct {
 	proto.UnimplementedPluginServer
 	logger hclog.Logger
-	config *proto.PluginConfig
+
+	// expiringWindow is the period before NotAfter in which certificates are reported as expiring
+	expiringWindow time.Duration
+
+	// trustStore holds the root certificates used for chain verification
+	trustStore *internal.TrustStore
+
+	// keyPassphrase is used to decrypt encrypted private keys
+	keyPassphrase []byte
+
+	// keyMaxMode is the most permissive private key file mode without a warning
+	keyMaxMode os.FileMode
+
+	// keyBlocklist holds the fingerprints of known compromised keys
+	keyBlocklist *internal.KeyBlocklist
+
+	// sharedPrimes caches the batch GCD over all RSA keys of the certificate directory
+	sharedPrimes *internal.SharedPrimeCache
+
+	// cache holds the analysis results of unchanged files
+	cache *internal.FileCache
+
+	// watcher refreshes the cache when dehydrated changes the certificate files, nil if disabled
+	watcher *internal.Watcher
+
+	// metrics holds the Prometheus metrics of the analyses, nil if disabled
+	metrics *internal.Metrics
+
+	// metricsServer serves the metrics on /metrics, nil if disabled
+	metricsServer *internal.MetricsServer
+
+	// metricsAddress is the address metricsServer was started for
+	metricsAddress string
+
+	// accounts enables the analysis of dehydrated's ACME accounts directory
+	accounts bool
 }
 
 // Initialize implements the plugin.Plugin interface
 func (p *OpensslPlugin) Initialize(_ context.Context, req *proto.InitializeRequest) (*proto.InitializeResponse, error) {
-	p.config.FromProto(req.Config)
+	cfg, err := ParseConfig(req.GetConfig())
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin configuration: %w", err)
+	}
 
-	if logLevel, err := p.config.GetString("logLevel"); err == nil {
-		p.logger.SetLevel(hclog.LevelFromString(logLevel))
+	// Everything that can fail is prepared first, so a failed Initialize leaves the plugin unchanged
+	prepared, err := p.prepare(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The refresh of the old watcher reads the fields changed below
+	p.closeWatcher()
+
+	if cfg.LogLevel != "" {
+		p.logger.SetLevel(hclog.LevelFromString(cfg.LogLevel))
+	}
+	p.logger.Debug("Effective configuration", cfg.LogArgs()...)
+
+	p.expiringWindow = time.Duration(cfg.ExpiringDays) * 24 * time.Hour
+	p.trustStore = prepared.trustStore
+	p.keyPassphrase = prepared.keyPassphrase
+	p.keyMaxMode = os.FileMode(cfg.KeyMaxMode)
+	p.keyBlocklist = prepared.keyBlocklist
+	p.cache = internal.NewFileCache(cfg.CacheSize, time.Duration(cfg.CacheTTL))
+	// The scan is rebuilt since Initialize may change the passphrase the keys are decrypted with
+	p.sharedPrimes = internal.NewSharedPrimeCache(internal.DefaultSharedPrimesTTL, internal.WithPassphrase(p.keyPassphrase))
+	if prepared.metricsServer != p.metricsServer {
+		p.closeMetrics()
+	}
+	p.metrics = prepared.metrics
+	p.metricsServer = prepared.metricsServer
+	p.metricsAddress = cfg.MetricsAddress
+	p.accounts = cfg.Accounts
+
+	// The watcher only reports changes once it watches a directory, which the refresh may now read the fields of
+	p.watcher = prepared.watcher
+	if p.watcher != nil && cfg.CertDir != "" {
+		if err = p.watcher.Watch(cfg.CertDir); err != nil {
+			return nil, err
+		}
 	}
 
 	p.logger.Debug("Initialize called")
@@ -41,9 +116,112 @@ func (p *OpensslPlugin) Initialize(_ context.Context, req *proto.InitializeReque
 	return &proto.InitializeResponse{}, nil
 }
 
+// preparedConfig holds the parts of the configuration that are read from files or started by Initialize.
+type preparedConfig struct {
+	trustStore    *internal.TrustStore
+	keyPassphrase []byte
+	keyBlocklist  *internal.KeyBlocklist
+	metrics       *internal.Metrics
+	metricsServer *internal.MetricsServer
+	watcher       *internal.Watcher
+}
+
+// prepare loads the files of the configuration and starts the metrics server and the watcher without changing the
+// plugin. A metrics server already listening on the configured address is kept.
+func (p *OpensslPlugin) prepare(cfg *Config) (*preparedConfig, error) {
+	prepared := &preparedConfig{trustStore: internal.SystemTrustStore()}
+	if cfg.CABundle != "" {
+		trustStore, err := internal.LoadTrustStore(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CA bundle: %w", err)
+		}
+		prepared.trustStore = trustStore
+	}
+	if err := prepared.loadKeyFiles(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := p.prepareMetrics(cfg, prepared); err != nil {
+		return nil, err
+	}
+	if err := p.prepareWatcher(cfg, prepared); err != nil {
+		if prepared.metricsServer != p.metricsServer {
+			_ = prepared.metricsServer.Close()
+		}
+		return nil, err
+	}
+
+	return prepared, nil
+}
+
+// loadKeyFiles reads the passphrase and the blocklist of the private key analysis.
+func (c *preparedConfig) loadKeyFiles(cfg *Config) error {
+	if cfg.KeyPassphrase != "" {
+		c.keyPassphrase = []byte(cfg.KeyPassphrase)
+	}
+	if cfg.KeyPassphraseFile != "" {
+		passphrase, err := os.ReadFile(cfg.KeyPassphraseFile)
+		if err != nil {
+			return fmt.Errorf("failed to read key passphrase file: %w", err)
+		}
+		c.keyPassphrase = bytes.TrimRight(passphrase, "\r\n")
+	}
+
+	if cfg.KeyBlocklist != "" {
+		blocklist, err := internal.LoadKeyBlocklist(cfg.KeyBlocklist)
+		if err != nil {
+			return fmt.Errorf("failed to load key blocklist: %w", err)
+		}
+		c.keyBlocklist = blocklist
+	}
+
+	return nil
+}
+
+// prepareMetrics starts the metrics server if the metricsAddress option is set and no server listens on it yet.
+func (p *OpensslPlugin) prepareMetrics(cfg *Config, prepared *preparedConfig) error {
+	if cfg.MetricsAddress == "" {
+		return nil
+	}
+	if p.metricsServer != nil && cfg.MetricsAddress == p.metricsAddress {
+		prepared.metrics, prepared.metricsServer = p.metrics, p.metricsServer
+		return nil
+	}
+
+	metrics := internal.NewMetrics()
+	server, err := internal.StartMetricsServer(cfg.MetricsAddress, metrics, p.logger)
+	if err != nil {
+		return fmt.Errorf("failed to start metrics server: %w", err)
+	}
+	prepared.metrics, prepared.metricsServer = metrics, server
+	return nil
+}
+
+// prepareWatcher creates the certificate directory watcher if the watch option is enabled. InitializeRequest does not
+// carry dehydrated's configuration, so the directory is taken from the certDir option or from the first GetMetadata call.
+func (p *OpensslPlugin) prepareWatcher(cfg *Config, prepared *preparedConfig) error {
+	if !cfg.Watch {
+		return nil
+	}
+	if cfg.CertDir != "" {
+		if _, err := os.ReadDir(cfg.CertDir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", cfg.CertDir, err)
+		}
+	}
+
+	watcher, err := internal.NewWatcher(p.logger, internal.DefaultWatchDelay, p.refresh)
+	if err != nil {
+		return err
+	}
+	prepared.watcher = watcher
+	return nil
+}
+
 // GetMetadata implements the plugin.Plugin interface
 func (p *OpensslPlugin) GetMetadata(_ context.Context, req *proto.GetMetadataRequest) (*proto.GetMetadataResponse, error) {
 	p.logger.Debug("GetMetadata called")
+	start := time.Now()
+	defer func() { p.metrics.ObserveCall(time.Since(start)) }()
 
 	// Create a new Metadata for the response
 	metadata := proto.NewMetadata()
@@ -62,37 +240,251 @@ func (p *OpensslPlugin) GetMetadata(_ context.Context, req *proto.GetMetadataReq
 		return metadata.ToGetMetadataResponse()
 	}
 
-	// Process certificate files
-	certFiles := map[string]string{
-		"key":       "privkey.pem",
-		"cert":      "cert.pem",
-		"chain":     "chain.pem",
-		"fullchain": "fullchain.pem",
-	}
-
-	for metadataKey, filename := range certFiles {
-		filePath := filepath.Join(domainDir, filename)
-		var value any
-
-		switch metadataKey {
-		case "key":
-			value = internal.NewKey(filePath)
-		default:
-			value = internal.NewCertificate(filePath)
+	if p.watcher != nil {
+		if err := p.watcher.Watch(req.GetDehydratedConfig().GetCertDir()); err != nil {
+			p.logger.Warn("Failed to watch certificate directory", "error", err)
 		}
+	}
+
+	// Process certificate files
+	now := time.Now()
+	key := p.newKey(filepath.Join(domainDir, "privkey.pem"), now)
+
+	cert := p.newCertificate(filepath.Join(domainDir, "cert.pem"), now)
+	chain := p.newChain(filepath.Join(domainDir, "chain.pem"), now)
+	fullchain := p.newChain(filepath.Join(domainDir, "fullchain.pem"), now)
 
-		_ = metadata.SetMap(metadataKey, value)
+	_ = metadata.SetMap("key", key)
+	_ = metadata.SetMap("cert", cert)
+	_ = metadata.SetMap("chain", chain)
+	_ = metadata.SetMap("fullchain", fullchain)
+	consistency := internal.NewConsistency(key, cert, chain, fullchain)
+	_ = metadata.SetMap("consistency", consistency)
+	p.metrics.ObserveDomain(dir, key, cert, chain, fullchain, consistency)
+
+	trustStore := p.trustStore
+	if trustStore == nil {
+		trustStore = internal.SystemTrustStore()
 	}
+	_ = metadata.SetMap("verification", internal.NewVerification(cert, chain, trustStore, now))
+
+	csr := p.loadCSR(filepath.Join(domainDir, "cert.csr"), now).Clone()
+	csr.Compare(key, cert)
+	_ = metadata.SetMap("csr", csr)
+
+	_ = metadata.SetMap("history", p.loadHistory(domainDir, now))
+
+	names := append([]string{req.GetDomainEntry().GetDomain()}, req.GetDomainEntry().GetAlternativeNames()...)
+	_ = metadata.SetMap("coverage", internal.NewCoverage(names, cert))
+
+	p.addOptionalFiles(metadata, domainDir, key, cert, chain, now)
+	p.addSharedPrimes(metadata, req, domainDir, now)
+	p.addAccounts(metadata, req, key, now)
+
+	hits, misses, entries := p.cache.Stats()
+	p.logger.Debug("Analysis cache", "hits", hits, "misses", misses, "entries", entries)
 
 	return metadata.ToGetMetadataResponse()
 }
 
+// keyOptions returns the options private keys are analyzed with.
+func (p *OpensslPlugin) keyOptions() []internal.KeyOption {
+	return []internal.KeyOption{
+		internal.WithPassphrase(p.keyPassphrase),
+		internal.WithMaxMode(p.keyMaxMode),
+		internal.WithBlocklist(p.keyBlocklist),
+	}
+}
+
+// loadKey returns the analysis of the private key, taken from the cache if the file is unchanged.
+// The result is shared and must be cloned before it is modified.
+func (p *OpensslPlugin) loadKey(file string, now time.Time) *internal.Key {
+	return internal.Cached(p.cache, file, now, func() *internal.Key {
+		return internal.NewKey(file, p.keyOptions()...)
+	})
+}
+
+// newAccountKey analyzes an ACME account key, or takes it from the cache if the file is unchanged.
+func (p *OpensslPlugin) newAccountKey(file string, now time.Time) *internal.Key {
+	key := internal.Cached(p.cache, file, now, func() *internal.Key {
+		return internal.NewKey(file)
+	}).Clone()
+	key.RefreshFileSecurity()
+	return key
+}
+
+// loadCertificate returns the analysis of the certificate, taken from the cache if the file is unchanged.
+// The result is shared and must be cloned before it is evaluated.
+func (p *OpensslPlugin) loadCertificate(file string, now time.Time) *internal.Certificate {
+	return internal.Cached(p.cache, file, now, func() *internal.Certificate {
+		return internal.NewCertificate(file)
+	})
+}
+
+// loadChain returns the analysis of the chain, taken from the cache if the file is unchanged.
+// The result is shared and must be cloned before it is evaluated.
+func (p *OpensslPlugin) loadChain(file string, now time.Time) *internal.Chain {
+	return internal.Cached(p.cache, file, now, func() *internal.Chain {
+		return internal.NewChain(file)
+	})
+}
+
+// loadCSR returns the analysis of the CSR, taken from the cache if the file is unchanged.
+// The result is shared and must be cloned before it is compared.
+func (p *OpensslPlugin) loadCSR(file string, now time.Time) *internal.CSR {
+	return internal.Cached(p.cache, file, now, func() *internal.CSR {
+		return internal.NewCSR(file)
+	})
+}
+
+// loadHistory returns the history of the domain directory, taken from the cache if the directory is unchanged.
+// The directory changes whenever dehydrated adds a version or swaps the symlinks.
+func (p *OpensslPlugin) loadHistory(domainDir string, now time.Time) *internal.History {
+	return internal.Cached(p.cache, domainDir, now, func() *internal.History {
+		return internal.NewHistory(domainDir, p.keyOptions()...)
+	})
+}
+
+// refresh analyzes the files of a changed domain directory in the background,
+// so the next GetMetadata call takes the results from the cache and the metrics show the new files.
+func (p *OpensslPlugin) refresh(domainDir string) {
+	if p.sharedPrimes != nil {
+		p.sharedPrimes.Invalidate()
+	}
+	if p.cache == nil && p.metrics == nil {
+		return
+	}
+
+	now := time.Now()
+	key := p.loadKey(filepath.Join(domainDir, "privkey.pem"), now)
+	cert := p.loadCertificate(filepath.Join(domainDir, "cert.pem"), now)
+	chain := p.loadChain(filepath.Join(domainDir, "chain.pem"), now)
+	fullchain := p.loadChain(filepath.Join(domainDir, "fullchain.pem"), now)
+	p.metrics.ObserveDomain(filepath.Base(domainDir), key, cert, chain, fullchain,
+		internal.NewConsistency(key, cert, chain, fullchain))
+
+	if p.cache != nil {
+		p.loadCSR(filepath.Join(domainDir, "cert.csr"), now)
+		p.loadHistory(domainDir, now)
+	}
+}
+
+// newKey analyzes the private key, or takes it from the cache, and logs its weaknesses and file security warnings.
+func (p *OpensslPlugin) newKey(file string, now time.Time) *internal.Key {
+	key := p.loadKey(file, now).Clone()
+	key.RefreshFileSecurity()
+	for _, finding := range key.Findings {
+		p.logger.Warn("Weak private key", "file", key.File, "check", finding.Check, "severity", finding.Severity)
+	}
+	if key.FileSecurity != nil {
+		for _, warning := range key.FileSecurity.Warnings {
+			p.logger.Warn("Private key file security", "file", key.File, "warning", warning)
+		}
+	}
+	return key
+}
+
+// newCertificate analyzes the certificate, or takes it from the cache, and evaluates its validity at now.
+func (p *OpensslPlugin) newCertificate(file string, now time.Time) *internal.Certificate {
+	cert := p.loadCertificate(file, now).Clone()
+	cert.Evaluate(now, p.expiringWindow)
+	return cert
+}
+
+// newChain analyzes the chain, or takes it from the cache, and evaluates the validity of its certificates at now.
+func (p *OpensslPlugin) newChain(file string, now time.Time) *internal.Chain {
+	chain := p.loadChain(file, now).Clone()
+	chain.Evaluate(now, p.expiringWindow)
+	return chain
+}
+
+// addOptionalFiles adds the metadata of the files dehydrated only creates in some setups.
+func (p *OpensslPlugin) addOptionalFiles(metadata *proto.Metadata, domainDir string,
+	key *internal.Key, cert *internal.Certificate, chain *internal.Chain, now time.Time) {
+	// OCSP staples are only present if dehydrated runs with OCSP_FETCH enabled
+	ocspFile := filepath.Join(domainDir, "ocsp.der")
+	if _, err := os.Lstat(ocspFile); err == nil {
+		_ = metadata.SetMap("ocsp", internal.NewOCSPStaple(ocspFile, cert, chain, now))
+	}
+
+	// The per-certificate config file is optional
+	configFile := filepath.Join(domainDir, "config")
+	if _, err := os.Lstat(configFile); err == nil {
+		certConfig := internal.NewCertConfig(configFile)
+		certConfig.Compare(key, chain)
+		_ = metadata.SetMap("config", certConfig)
+	}
+}
+
+// addSharedPrimes adds the result of the cached batch GCD over all keys for the keys of the domain.
+func (p *OpensslPlugin) addSharedPrimes(metadata *proto.Metadata, req *proto.GetMetadataRequest, domainDir string, now time.Time) {
+	if p.sharedPrimes == nil {
+		return
+	}
+	sharedPrimes := p.sharedPrimes.Get(req.GetDehydratedConfig().GetCertDir(), now).Domain(filepath.Base(domainDir))
+	if sharedPrimes.Shared {
+		p.logger.Warn("Private key shares a prime with another key", "domain", req.GetDomainEntry().GetDomain())
+	}
+	_ = metadata.SetMap("shared_primes", sharedPrimes)
+}
+
+// addAccounts compares the private key with the ACME account keys and, if enabled, adds the accounts.
+func (p *OpensslPlugin) addAccounts(metadata *proto.Metadata, req *proto.GetMetadataRequest, key *internal.Key,
+	now time.Time) {
+	accountsDir := req.GetDehydratedConfig().GetAccountsDir()
+	if accountsDir == "" && !p.accounts {
+		return
+	}
+
+	accounts := internal.NewAccounts(accountsDir, internal.WithKeyLoader(func(file string) *internal.Key {
+		return p.newAccountKey(file, now)
+	}))
+	// Account keys are always compared with the private key, the account details are only reported on request
+	if accountsDir != "" {
+		reuse := internal.NewKeyReuse(key, accounts)
+		if reuse.Reused {
+			p.logger.Warn("Private key is also used as ACME account key", "domain", req.GetDomainEntry().GetDomain())
+		}
+		_ = metadata.SetMap("account_key_reuse", reuse)
+	}
+	if p.accounts {
+		_ = metadata.SetMap("accounts", accounts)
+	}
+}
+
 // Close implements the plugin.Plugin interface
 func (p *OpensslPlugin) Close(_ context.Context, _ *proto.CloseRequest) (*proto.CloseResponse, error) {
 	p.logger.Debug("Close called")
+	p.closeWatcher()
+	if p.sharedPrimes != nil {
+		p.sharedPrimes.Wait()
+	}
+	p.closeMetrics()
 	return &proto.CloseResponse{}, nil
 }
 
+// closeWatcher stops the watcher, if running, and waits for a running refresh to return.
+func (p *OpensslPlugin) closeWatcher() {
+	if p.watcher != nil {
+		if err := p.watcher.Close(); err != nil {
+			p.logger.Warn("Failed to close watcher", "error", err)
+		}
+	}
+	p.watcher = nil
+}
+
+// closeMetrics stops the metrics server, if running.
+func (p *OpensslPlugin) closeMetrics() {
+	if p.metricsServer != nil {
+		if err := p.metricsServer.Close(); err != nil {
+			p.logger.Warn("Failed to close metrics server", "error", err)
+		}
+	}
+	p.metrics = nil
+	p.metricsServer = nil
+	p.metricsAddress = ""
+}
+
 func main() {
 	// Parse command line flags
 	versionFlag := flag.Bool("version", false, "Print version information")
@@ -103,6 +495,11 @@ func main() {
 		printVersionInfoAndExit()
 	}
 
+	// Run a command instead of the plugin server
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args(), os.Stdout, os.Stderr))
+	}
+
 	logger := hclog.New(&hclog.LoggerOptions{
 		Name:       "openssl-plugin",
 		Level:      hclog.Trace,
@@ -111,13 +508,28 @@ func main() {
 	})
 
 	plugin := &OpensslPlugin{
-		logger: logger,
-		config: proto.NewPluginConfig(),
+		logger:         logger,
+		expiringWindow: internal.DefaultExpiringWindow,
+		trustStore:     internal.SystemTrustStore(),
+		keyMaxMode:     internal.DefaultKeyMaxMode,
 	}
 
 	server.NewPluginServer(plugin).Serve()
 }
 
+// runCommand runs the command named by the first argument and returns its exit code.
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "analyze":
+		return runAnalyze(args[1:], stdout, stderr)
+	case "check":
+		return runCheck(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		return exitUsage
+	}
+}
+
 // printVersionInfoAndExit prints the version information as a formatted string and exists the program
 func printVersionInfoAndExit() {
 	fmt.Printf("Version: %s\nCommit: %s\nBuild Time: %s\n", Version, Commit, BuildTime)